@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-shellwords"
+)
+
+var (
+	AlertRules    stringListFlag // alert rules, e.g. failures:3
+	AlertWebhooks stringListFlag // URLs that get a JSON payload on every alert
+	AlertCommands stringListFlag // commands that are executed on every alert
+	AlertBell     = flag.Bool("alert-bell", false, "ring the terminal bell on every alert")
+)
+
+const (
+	alertActionTimeout = 10 * time.Second // maximum time of a single webhook or command
+	alertMinimumTests  = 5                // the errors and p95 rules need this amount of tests within the window
+)
+
+// defineAlertFlags defines the command line flags of the alerts which can be given multiple times
+func defineAlertFlags() {
+	flag.Var(&AlertRules,
+		"alert",
+		"alert `rule`: failures:N, errors:P%/window or p95:Dms/window, the recovery threshold can be appended with ~, "+
+			"can be given multiple times",
+	)
+	flag.Var(&AlertWebhooks,
+		"alert-webhook",
+		"send alerts as JSON to the `URL` (Slack and Mattermost compatible), can be given multiple times",
+	)
+	flag.Var(&AlertCommands,
+		"alert-exec",
+		"execute the `command` on alerts with the details in PC_* environment variables, can be given multiple times",
+	)
+}
+
+/*
+ * AlertRule
+ */
+
+// AlertKind is the value which is checked by an AlertRule
+type AlertKind int
+
+const (
+	AlertKindFailures AlertKind = iota // consecutive failed tests
+	AlertKindErrors                    // error percentage within a window
+	AlertKindP95                       // 95th percentile of the delay within a window
+)
+
+// AlertRule triggers an alert if a threshold is exceeded and resolves it when the value is back at the
+// recovery threshold, so a value that is close to the threshold does not alert on every test
+type AlertRule struct {
+	Spec      string        // the rule as given by the user
+	Kind      AlertKind     // the checked value
+	Threshold float64       // amount of failures, error percentage or delay in ms that triggers the alert
+	Recover   float64       // amount of successes, error percentage or delay in ms that resolves the alert
+	Window    time.Duration // time range of the errors and p95 rules
+}
+
+// ParseAlertRule parses a rule like "failures:3", "errors:20%/5m~10%" or "p95:200ms/1m~150ms"
+func ParseAlertRule(spec string) (AlertRule, error) {
+	rule := AlertRule{Spec: spec, Window: time.Minute}
+
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 {
+		return rule, errors.New("expected kind:threshold")
+	}
+	value := parts[1]
+
+	// optional recovery threshold
+	recovery := ""
+	if i := strings.Index(value, "~"); i != -1 {
+		value, recovery = value[:i], value[i+1:]
+	}
+
+	switch parts[0] {
+	case "failures":
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 1 {
+			return rule, errors.New("invalid amount of failures " + value)
+		}
+		rule.Kind = AlertKindFailures
+		rule.Threshold = float64(count)
+		rule.Recover = float64(count)
+		if recovery != "" {
+			count, err = strconv.Atoi(recovery)
+			if err != nil || count < 1 {
+				return rule, errors.New("invalid amount of successes " + recovery)
+			}
+			rule.Recover = float64(count)
+		}
+		return rule, nil
+	case "errors":
+		rule.Kind = AlertKindErrors
+	case "p95":
+		rule.Kind = AlertKindP95
+	default:
+		return rule, errors.New("unknown kind " + parts[0] + ", available: failures, errors, p95")
+	}
+
+	// the errors and p95 rules have a window
+	if i := strings.Index(value, "/"); i != -1 {
+		window, err := time.ParseDuration(value[i+1:])
+		if err != nil || window <= 0 || window > longestStatisticWindow {
+			return rule, fmt.Errorf("invalid window %s, at most %s", value[i+1:], longestStatisticWindow)
+		}
+		rule.Window = window
+		value = value[:i]
+	}
+
+	var err error
+	if rule.Threshold, err = parseAlertThreshold(rule.Kind, value); err != nil {
+		return rule, err
+	}
+	// by default the value must drop by a fifth to resolve the alert
+	rule.Recover = rule.Threshold * 0.8
+	if recovery != "" {
+		if rule.Recover, err = parseAlertThreshold(rule.Kind, recovery); err != nil {
+			return rule, err
+		}
+	}
+	if rule.Recover > rule.Threshold {
+		return rule, errors.New("the recovery threshold must not be above the threshold")
+	}
+
+	return rule, nil
+}
+
+// parseAlertThreshold parses a percentage for AlertKindErrors or a duration for AlertKindP95 into a float
+func parseAlertThreshold(kind AlertKind, value string) (float64, error) {
+	if kind == AlertKindErrors {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return 0, errors.New("invalid percentage " + value)
+		}
+		return percent, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, errors.New("invalid delay " + value)
+	}
+	return float64(d) / float64(time.Millisecond), nil
+}
+
+// check returns if the alert of the rule is active after the last test and a description of the checked value
+func (r AlertRule) check(s *Server, active bool) (bool, string) {
+	if r.Kind == AlertKindFailures {
+		failures, successes := getConsecutiveResults(s.Answers)
+		if !active && float64(failures) >= r.Threshold {
+			return true, fmt.Sprintf("%d consecutive failures", failures)
+		}
+		if active && float64(successes) >= r.Recover {
+			return false, fmt.Sprintf("%d consecutive successes", successes)
+		}
+		return active, ""
+	}
+
+	if len(s.Recent) == 0 {
+		return active, ""
+	}
+	st := getWindowStatistics(s.Recent, s.Recent[len(s.Recent)-1].Time.Add(-r.Window))
+	if st.GetQuerySum() < alertMinimumTests {
+		return active, ""
+	}
+
+	value, description := st.GetErrorPercentage(), ""
+	if r.Kind == AlertKindErrors {
+		description = fmt.Sprintf("%.2f%% errors within %s", value, r.Window)
+	} else {
+		value = float64(st.P95Delay) / float64(time.Millisecond)
+		description = fmt.Sprintf("p95 of %s within %s", formatDelay(st.P95Delay), r.Window)
+	}
+
+	if !active && value > r.Threshold {
+		return true, description
+	}
+	if active && value <= r.Recover {
+		return false, description
+	}
+	return active, ""
+}
+
+// getConsecutiveResults returns the amount of failures or successes at the end of the answers
+func getConsecutiveResults(answers []TestResult) (int, int) {
+	failures, successes := 0, 0
+	for i := len(answers) - 1; i >= 0; i-- {
+		if answers[i].Result {
+			if failures > 0 {
+				break
+			}
+			successes++
+		} else {
+			if successes > 0 {
+				break
+			}
+			failures++
+		}
+	}
+	return failures, successes
+}
+
+/*
+ * AlertEvent
+ */
+
+// AlertEvent is sent to all actions if an alert is triggered or resolved
+type AlertEvent struct {
+	Time    time.Time `json:"time"`
+	Target  string    `json:"target"`
+	Plugin  string    `json:"plugin"`
+	Rule    string    `json:"rule"`
+	State   string    `json:"state"` // "down" if the alert is triggered, "up" if it is resolved
+	Message string    `json:"text"`  // Slack and Mattermost show the text field
+}
+
+// getEnvironment returns the event as environment variables for commands
+func (e AlertEvent) getEnvironment() []string {
+	return []string{
+		"PC_TIME=" + e.Time.Format(time.RFC3339),
+		"PC_TARGET=" + e.Target,
+		"PC_PLUGIN=" + e.Plugin,
+		"PC_RULE=" + e.Rule,
+		"PC_STATE=" + e.State,
+		"PC_MESSAGE=" + e.Message,
+	}
+}
+
+/*
+ * Alerting
+ */
+
+// Alerting checks all AlertRule after every test and executes the actions on state changes
+type Alerting struct {
+	Rules     []AlertRule
+	Webhooks  []string   // URLs that get the AlertEvent as JSON
+	Commands  [][]string // commands that get the AlertEvent as environment variables
+	Bell      bool       // ring the terminal bell on every AlertEvent
+	LastError error      // last error of an action, shown in the user interface
+
+	active  map[int][]bool // active alerts per Server ID and rule
+	bell    bool           // true if the bell should ring at the next rendering
+	pending []AlertEvent   // events whose actions are not executed yet, oldest first
+	wake    chan struct{}  // signals the worker that events are pending
+	mutex   sync.Mutex
+}
+
+// NewAlerting creates the Alerting from the command line flags, nil if no rules are given
+func NewAlerting() (*Alerting, error) {
+	if len(AlertRules) == 0 {
+		if len(AlertWebhooks) > 0 || len(AlertCommands) > 0 || *AlertBell {
+			return nil, errors.New("alert actions need at least one -alert rule")
+		}
+		return nil, nil
+	}
+
+	a := &Alerting{
+		Webhooks: AlertWebhooks,
+		Bell:     *AlertBell,
+		active:   make(map[int][]bool),
+	}
+	for _, spec := range AlertRules {
+		rule, err := ParseAlertRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", spec, err)
+		}
+		a.Rules = append(a.Rules, rule)
+	}
+	for _, command := range AlertCommands {
+		c, err := shellwords.Parse(command)
+		if err != nil || len(c) == 0 {
+			return nil, fmt.Errorf("invalid command %q", command)
+		}
+		a.Commands = append(a.Commands, c)
+	}
+
+	a.startWorker()
+	return a, nil
+}
+
+// startWorker starts the single worker that executes the actions, so the events of a Server arrive in order
+func (a *Alerting) startWorker() {
+	a.wake = make(chan struct{}, 1)
+	go func() {
+		for range a.wake {
+			for {
+				a.mutex.Lock()
+				if len(a.pending) == 0 {
+					a.mutex.Unlock()
+					break
+				}
+				event := a.pending[0]
+				a.pending = a.pending[1:]
+				a.mutex.Unlock()
+
+				a.execute(event)
+			}
+		}
+	}()
+}
+
+// Check checks all rules for the Server after its last test, it is called concurrently for different Server
+func (a *Alerting) Check(s *Server) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	active, ok := a.active[s.ID]
+	if !ok {
+		active = make([]bool, len(a.Rules))
+		a.active[s.ID] = active
+	}
+
+	for i, rule := range a.Rules {
+		state, description := rule.check(s, active[i])
+		if state == active[i] {
+			continue
+		}
+		active[i] = state
+
+		event := AlertEvent{
+			Time:   time.Now(),
+			Target: s.GetName(),
+			Plugin: s.Plugin,
+			Rule:   rule.Spec,
+		}
+		if state {
+			event.State = "down"
+			event.Message = fmt.Sprintf("%s is down: %s (rule %s)", event.Target, description, rule.Spec)
+		} else {
+			event.State = "up"
+			event.Message = fmt.Sprintf("%s is up again: %s (rule %s)", event.Target, description, rule.Spec)
+		}
+
+		a.bell = a.bell || a.Bell
+		a.pending = append(a.pending, event)
+		select {
+		case a.wake <- struct{}{}:
+		default:
+			// the worker is already woken up
+		}
+	}
+}
+
+// RemoveServer forgets the alerts of a removed Server without executing the actions
+func (a *Alerting) RemoveServer(serverID int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	delete(a.active, serverID)
+}
+
+// IsActive returns true if an alert of the Server is active
+func (a *Alerting) IsActive(serverID int) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, active := range a.active[serverID] {
+		if active {
+			return true
+		}
+	}
+	return false
+}
+
+// RingBell returns true once after an alert if the terminal bell should ring
+func (a *Alerting) RingBell() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	bell := a.bell
+	a.bell = false
+	return bell
+}
+
+// Reset resolves all alerts without executing the actions
+func (a *Alerting) Reset() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.active = make(map[int][]bool)
+}
+
+// GetError returns the last error of an action
+func (a *Alerting) GetError() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.LastError
+}
+
+// execute runs all actions for the event
+func (a *Alerting) execute(event AlertEvent) {
+	for _, url := range a.Webhooks {
+		a.setError(sendAlertWebhook(url, event))
+	}
+	for _, command := range a.Commands {
+		a.setError(runAlertCommand(command, event))
+	}
+}
+
+func (a *Alerting) setError(err error) {
+	if err == nil {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.LastError = err
+}
+
+// sendAlertWebhook posts the event as JSON to the URL
+func sendAlertWebhook(url string, event AlertEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	client := http.Client{Timeout: alertActionTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook failed: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// runAlertCommand executes the command with the event as environment variables
+func runAlertCommand(command []string, event AlertEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), alertActionTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), event.getEnvironment()...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command %s failed: %w", command[0], err)
+	}
+	return nil
+}
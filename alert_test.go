@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    AlertRule
+		wantErr bool
+	}{
+		{
+			spec: "failures:3",
+			want: AlertRule{Kind: AlertKindFailures, Threshold: 3, Recover: 3, Window: time.Minute},
+		},
+		{
+			spec: "failures:3~1",
+			want: AlertRule{Kind: AlertKindFailures, Threshold: 3, Recover: 1, Window: time.Minute},
+		},
+		{
+			// the recovery threshold is a fifth below the threshold by default
+			spec: "errors:20%/5m",
+			want: AlertRule{Kind: AlertKindErrors, Threshold: 20, Recover: 16, Window: 5 * time.Minute},
+		},
+		{
+			spec: "errors:20%~5%",
+			want: AlertRule{Kind: AlertKindErrors, Threshold: 20, Recover: 5, Window: time.Minute},
+		},
+		{
+			spec: "p95:200ms/1m~150ms",
+			want: AlertRule{Kind: AlertKindP95, Threshold: 200, Recover: 150, Window: time.Minute},
+		},
+		{spec: "failures", wantErr: true},
+		{spec: "failures:0", wantErr: true},
+		{spec: "failures:3~x", wantErr: true},
+		{spec: "errors:120%", wantErr: true},
+		{spec: "errors:10%~20%", wantErr: true},
+		{spec: "errors:10%/1y", wantErr: true},
+		{spec: "p95:-5ms", wantErr: true},
+		{spec: "latency:5ms", wantErr: true},
+	}
+
+	for _, test := range tests {
+		rule, err := ParseAlertRule(test.spec)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseAlertRule(%q) = %+v, want an error", test.spec, rule)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAlertRule(%q): %v", test.spec, err)
+			continue
+		}
+		test.want.Spec = test.spec
+		if rule != test.want {
+			t.Errorf("ParseAlertRule(%q) = %+v, want %+v", test.spec, rule, test.want)
+		}
+	}
+}
+
+func TestAlertRuleCheck(t *testing.T) {
+	tests := []struct {
+		rule    string
+		results string // results of the tests, . is a success and x a failure
+		active  string // state of the alert after each test, 1 if it is active
+	}{
+		// the alert is resolved after as many successes as failures triggered it
+		{rule: "failures:3", results: "x.xxx..x...", active: "00001111110"},
+		{rule: "failures:2~1", results: "xx.xxx.", active: "0100110"},
+		// at least 5 tests are needed, one test is done per second
+		{rule: "errors:50%/5s~20%", results: "xxxx.....", active: "000011100"},
+		// between the thresholds the state is kept, 40% errors neither trigger nor resolve the alert
+		{rule: "errors:50%/5s~20%", results: "....xx.x.xx....", active: "000000011111110"},
+	}
+
+	for _, test := range tests {
+		rule, err := ParseAlertRule(test.rule)
+		if err != nil {
+			t.Fatalf("ParseAlertRule(%q): %v", test.rule, err)
+		}
+
+		s := &Server{}
+		start := time.Now()
+		active := false
+		states := ""
+		for i, r := range test.results {
+			result := TestResult{Time: start.Add(time.Duration(i) * time.Second), Result: r == '.'}
+			s.Answers = append(s.Answers, result)
+			s.Recent = append(s.Recent, result)
+			active, _ = rule.check(s, active)
+			if active {
+				states += "1"
+			} else {
+				states += "0"
+			}
+		}
+		if states != test.active {
+			t.Errorf("%s with %s: active %s, want %s", test.rule, test.results, states, test.active)
+		}
+	}
+}
+
+func TestAlertingActionsInOrder(t *testing.T) {
+	states := make([]string, 0)
+	mutex := sync.Mutex{}
+	received := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := AlertEvent{}
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		// a slow first action must not let the later events overtake it
+		if event.State == "down" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		mutex.Lock()
+		states = append(states, event.State)
+		mutex.Unlock()
+		received <- struct{}{}
+	}))
+	defer server.Close()
+
+	rule, err := ParseAlertRule("failures:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Alerting{Rules: []AlertRule{rule}, Webhooks: []string{server.URL}, active: make(map[int][]bool)}
+	a.startWorker()
+
+	s := &Server{ID: 1, TestPlugin: &replayCollector{name: "target"}}
+	for _, result := range []bool{false, true, false, true} {
+		s.Answers = append(s.Answers, TestResult{Result: result})
+		a.Check(s)
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of 4 events received", i)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if want := []string{"down", "up", "down", "up"}; !reflect.DeepEqual(states, want) {
+		t.Errorf("states = %v, want %v", states, want)
+	}
+	if err = a.GetError(); err != nil {
+		t.Errorf("GetError() = %v", err)
+	}
+}
+
+func TestAlertingRemoveServer(t *testing.T) {
+	rule, err := ParseAlertRule("failures:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Alerting{Rules: []AlertRule{rule}, active: make(map[int][]bool)}
+	a.startWorker()
+
+	s := &Server{ID: 1, TestPlugin: &replayCollector{name: "target"}, Answers: []TestResult{{Result: false}}}
+	a.Check(s)
+	if !a.IsActive(1) {
+		t.Fatal("alert is not active after a failure")
+	}
+
+	a.RemoveServer(1)
+	if a.IsActive(1) || len(a.active) != 0 {
+		t.Errorf("the alerts of the removed Server are kept: %v", a.active)
+	}
+}
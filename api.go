@@ -0,0 +1,324 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+The API allows to control a running instance with HTTP requests and JSON bodies:
+
+	GET    /targets                    list all targets with their statistics
+	POST   /targets                    add a target: {"address": "8.8.8.8", "plugin": "dns", "labels": ["dc1"], "options": {"domain": "example.org"}}
+	DELETE /targets/<id>               remove a target
+	POST   /targets/<id>/pause         stop testing a target
+	POST   /targets/<id>/resume        test a paused target again
+	POST   /targets/<id>/reset         clear the statistics of a target
+	POST   /pause, /resume, /reset     the same for all targets
+	GET    /settings                   show the interval and timeout
+	PUT    /settings                   change them: {"interval": "500ms", "timeout": "1s"}
+
+Errors are answered with {"error": "..."} and a matching status code. With a token every request needs the header
+"Authorization: Bearer <token>", without a token the API only listens on loopback addresses.
+*/
+
+// apiTokenEnv is the environment variable with the token of the API if -api-token is not given
+const apiTokenEnv = "PC_API_TOKEN"
+
+// apiTarget is a single target of the API
+type apiTarget struct {
+	ID              int               `json:"id"`
+	Name            string            `json:"name"`
+	Address         string            `json:"address"`
+	Plugin          string            `json:"plugin"`
+	Labels          []string          `json:"labels"`
+	Config          map[string]string `json:"config"`
+	Paused          bool              `json:"paused"`
+	Down            bool              `json:"down"`
+	Success         int               `json:"success"`
+	Errors          int               `json:"errors"`
+	ErrorPercentage float64           `json:"error_percentage"`
+	LastMS          float64           `json:"last_ms"`
+	AverageMS       float64           `json:"average_ms"`
+	P95MS           float64           `json:"p95_ms"`
+	BestMS          float64           `json:"best_ms"`
+	WorstMS         float64           `json:"worst_ms"`
+	Outages         int               `json:"outages"`
+}
+
+// apiNewTarget is the body to add a target
+type apiNewTarget struct {
+	Address string            `json:"address"`
+	Plugin  string            `json:"plugin"`  // the plugin of the command line if empty
+	Labels  []string          `json:"labels"`  // optional labels
+	Options map[string]string `json:"options"` // plugin options by their flag name, e.g. "domain"
+}
+
+// apiSettings are the global settings of the API
+type apiSettings struct {
+	Interval string `json:"interval"`
+	Timeout  string `json:"timeout"`
+	Paused   bool   `json:"paused"`
+}
+
+// StartAPI listens on the address and serves the API in the background, requests need the token if it is not empty.
+// Without a token only loopback addresses are allowed because the API can add and remove targets.
+func StartAPI(address string, token string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	if tcpAddress, ok := listener.Addr().(*net.TCPAddr); token == "" && (!ok || !tcpAddress.IP.IsLoopback()) {
+		_ = listener.Close()
+		return fmt.Errorf("%s is reachable from the network, listen on a loopback address like 127.0.0.1:8080 or "+
+			"set a token with -api-token or %s", listener.Addr(), apiTokenEnv)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/targets", handleAPITargets)
+	mux.HandleFunc("/targets/", handleAPITarget)
+	mux.HandleFunc("/pause", handleAPIControl)
+	mux.HandleFunc("/resume", handleAPIControl)
+	mux.HandleFunc("/reset", handleAPIControl)
+	mux.HandleFunc("/settings", handleAPISettings)
+
+	server := &http.Server{Handler: requireAPIToken(token, mux), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	return nil
+}
+
+// requireAPIToken only passes requests with the bearer token to the handler, all requests are passed without a token
+func requireAPIToken(token string, handler http.Handler) http.Handler {
+	if token == "" {
+		return handler
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// handleAPITargets lists and adds targets
+func handleAPITargets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		globalState.ServerLock.Lock()
+		defer globalState.ServerLock.Unlock()
+		targets := make([]apiTarget, 0, len(globalState.Server))
+		for i := range globalState.Server {
+			targets = append(targets, getAPITarget(&globalState.Server[i]))
+		}
+		writeAPIResponse(w, http.StatusOK, targets)
+	case http.MethodPost:
+		target := apiNewTarget{}
+		if err := json.NewDecoder(r.Body).Decode(&target); err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+			return
+		}
+		if target.Address == "" {
+			writeAPIError(w, http.StatusBadRequest, errors.New("missing address"))
+			return
+		}
+		if target.Plugin == "" {
+			target.Plugin = PluginToUse
+		}
+		if !Plugins.IsRegistered(target.Plugin) {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("unknown plugin %s", target.Plugin))
+			return
+		}
+
+		// the plugin is configured without the ServerLock
+		s, err := globalState.NewServer(target.Address, target.Labels, target.Plugin, target.Options)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		globalState.ServerLock.Lock()
+		defer globalState.ServerLock.Unlock()
+		writeAPIResponse(w, http.StatusCreated, getAPITarget(globalState.addNewServer(s)))
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// handleAPITarget removes, pauses, resumes or resets a single target
+func handleAPITarget(w http.ResponseWriter, r *http.Request) {
+	globalState.ServerLock.Lock()
+	defer globalState.ServerLock.Unlock()
+
+	// /targets/<id>[/<action>]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/targets/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) > 2 {
+		writeAPIError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	index := globalState.getServerIndex(id)
+	if index == -1 {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("unknown target %d", id))
+		return
+	}
+	s := &globalState.Server[index]
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			writeAPIResponse(w, http.StatusOK, getAPITarget(s))
+		case http.MethodDelete:
+			_ = globalState.RemoveServer(id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		}
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	switch parts[1] {
+	case "pause":
+		s.Paused = true
+	case "resume":
+		s.Paused = false
+	case "reset":
+		s.Reset()
+	default:
+		writeAPIError(w, http.StatusNotFound, errors.New("unknown action "+parts[1]))
+		return
+	}
+	writeAPIResponse(w, http.StatusOK, getAPITarget(s))
+}
+
+// handleAPIControl pauses, resumes or resets all targets
+func handleAPIControl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	globalState.ServerLock.Lock()
+	switch r.URL.Path {
+	case "/pause":
+		globalState.Pause = true
+	case "/resume":
+		globalState.Pause = false
+	case "/reset":
+		// the reset is done between two test rounds
+		globalState.ResetState = true
+	}
+	settings := getAPISettings()
+	globalState.ServerLock.Unlock()
+
+	writeAPIResponse(w, http.StatusOK, settings)
+}
+
+// handleAPISettings shows or changes the interval and timeout
+func handleAPISettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		globalState.ServerLock.Lock()
+		settings := getAPISettings()
+		globalState.ServerLock.Unlock()
+		writeAPIResponse(w, http.StatusOK, settings)
+	case http.MethodPut, http.MethodPatch:
+		settings := apiSettings{}
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+			return
+		}
+
+		var interval, timeout time.Duration
+		var err error
+		if settings.Interval != "" {
+			if interval, err = time.ParseDuration(settings.Interval); err != nil || interval < minimumWaitTime {
+				writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid interval, the minimum is %s", minimumWaitTime))
+				return
+			}
+		}
+		if settings.Timeout != "" {
+			if timeout, err = time.ParseDuration(settings.Timeout); err != nil || timeout <= 0 {
+				writeAPIError(w, http.StatusBadRequest, errors.New("invalid timeout"))
+				return
+			}
+		}
+
+		globalState.ServerLock.Lock()
+		if settings.Interval != "" {
+			*WaitTime = interval
+		}
+		if settings.Timeout != "" {
+			globalState.Timeout = timeout
+			globalState.UpdateTimeouts()
+		}
+		settings = getAPISettings()
+		globalState.ServerLock.Unlock()
+
+		writeAPIResponse(w, http.StatusOK, settings)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// getAPITarget returns the Server with its statistics for the API
+func getAPITarget(s *Server) apiTarget {
+	st := s.GetStatistics(StatisticWindowAll)
+	labels := s.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+
+	return apiTarget{
+		ID:              s.ID,
+		Name:            s.GetName(),
+		Address:         s.Config["IPAddress"],
+		Plugin:          s.Plugin,
+		Labels:          labels,
+		Config:          Plugins.GetPublicConfig(s.Plugin, s.Config),
+		Paused:          s.Paused,
+		Down:            s.Down,
+		Success:         st.SuccessQueries,
+		Errors:          st.ErrorQueries,
+		ErrorPercentage: st.GetErrorPercentage(),
+		LastMS:          getMilliseconds(st.LastDelay),
+		AverageMS:       getMilliseconds(st.AverageDelay),
+		P95MS:           getMilliseconds(st.P95Delay),
+		BestMS:          getMilliseconds(st.BestDelay),
+		WorstMS:         getMilliseconds(st.WorstDelay),
+		Outages:         s.Outages,
+	}
+}
+
+// getAPISettings returns the global settings, the ServerLock must be held
+func getAPISettings() apiSettings {
+	return apiSettings{
+		Interval: WaitTime.String(),
+		Timeout:  globalState.Timeout.String(),
+		Paused:   globalState.Pause,
+	}
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIResponse(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// configCheckInterval is how often the config file is checked for changes
+const configCheckInterval = 2 * time.Second
+
+/**
+The config file contains the targets and settings of a long-lived instance as JSON:
+
+	{
+	  "interval": "1s",
+	  "timeout": "500ms",
+	  "targets": [
+	    "dns dc1=8.8.8.8 domain=example.org",
+	    {"address": "1.1.1.1", "plugin": "dns", "labels": ["dc2"], "options": {"type": "AAAA"}}
+	  ]
+	}
+
+Targets are given like in the prompt of the user interface or as objects like for the API. The
+settings override the command line flags. The file is reloaded on SIGHUP and when it changes.
+*/
+
+// Config is the content of the config file
+type Config struct {
+	Interval string             `json:"interval"`
+	Timeout  string             `json:"timeout"`
+	Targets  []configTargetJSON `json:"targets"`
+}
+
+// configTargetJSON is a target of the config file, either a spec string or an object
+type configTargetJSON struct {
+	apiNewTarget
+}
+
+func (t *configTargetJSON) UnmarshalJSON(data []byte) error {
+	spec := ""
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return json.Unmarshal(data, &t.apiNewTarget)
+	}
+
+	target, err := parseTargetSpec(spec)
+	if err != nil {
+		return fmt.Errorf("invalid target %q: %w", spec, err)
+	}
+	t.apiNewTarget = target
+	return nil
+}
+
+// getKey returns a string which identifies the target to find unchanged targets at a reload
+func (t apiNewTarget) getKey() string {
+	labels := make([]string, len(t.Labels))
+	copy(labels, t.Labels)
+	sort.Strings(labels)
+
+	options := make([]string, 0, len(t.Options))
+	for name, value := range t.Options {
+		options = append(options, name+"="+value)
+	}
+	sort.Strings(options)
+
+	return t.Plugin + "|" + t.Address + "|" + strings.Join(labels, ",") + "|" + strings.Join(options, " ")
+}
+
+// LoadConfig reads and validates the config file
+func LoadConfig(path string) (Config, error) {
+	config := Config{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return config, err
+	}
+
+	if config.Interval != "" {
+		if d, err := time.ParseDuration(config.Interval); err != nil || d < minimumWaitTime {
+			return config, fmt.Errorf("invalid interval, the minimum is %s", minimumWaitTime)
+		}
+	}
+	if config.Timeout != "" {
+		if d, err := time.ParseDuration(config.Timeout); err != nil || d <= 0 {
+			return config, errors.New("invalid timeout")
+		}
+	}
+	for i := range config.Targets {
+		t := &config.Targets[i].apiNewTarget
+		if t.Address == "" {
+			return config, fmt.Errorf("target %d: missing address", i+1)
+		}
+		if t.Plugin == "" {
+			t.Plugin = PluginToUse
+		}
+		if !Plugins.IsRegistered(t.Plugin) {
+			return config, fmt.Errorf("target %d: unknown plugin %s", i+1, t.Plugin)
+		}
+	}
+
+	return config, nil
+}
+
+// ApplyConfig applies the settings of the config and adds or removes the targets which have changed since the last
+// config, targets that are still the same keep their statistics. All new targets are configured before anything is
+// changed, so a config with an invalid target leaves the state untouched. The plugins are configured without holding
+// the ServerLock, so it must not be held, see NewServer.
+func (gs *GlobalStateType) ApplyConfig(config Config) (added int, removed int, err error) {
+	gs.ServerLock.Lock()
+	timeout := gs.Timeout
+	if config.Timeout != "" {
+		timeout, _ = time.ParseDuration(config.Timeout)
+	}
+	remove, add := gs.getConfigChanges(config)
+	gs.ServerLock.Unlock()
+
+	servers := make([]Server, 0, len(add))
+	for _, t := range add {
+		s, err := newConfiguredServer(t.Address, t.Labels, t.Plugin, t.Options, timeout)
+		if err != nil {
+			for i := range servers {
+				servers[i].Close()
+			}
+			return 0, 0, fmt.Errorf("could not add %s: %w", t.Address, err)
+		}
+		s.ConfigKey = t.getKey()
+		servers = append(servers, s)
+	}
+
+	gs.ServerLock.Lock()
+	defer gs.ServerLock.Unlock()
+
+	// nothing can fail from here on
+	if config.Interval != "" {
+		*WaitTime, _ = time.ParseDuration(config.Interval)
+	}
+	if config.Timeout != "" && timeout != gs.Timeout {
+		gs.Timeout = timeout
+		gs.UpdateTimeouts()
+	}
+	// the Server could have been removed in the meantime, e.g. by the API
+	for _, id := range remove {
+		_ = gs.RemoveServer(id)
+	}
+	for _, s := range servers {
+		gs.addNewServer(s)
+	}
+
+	return len(servers), len(remove), nil
+}
+
+// getConfigChanges returns the IDs of the Server of the last config which are not wanted anymore and the targets of
+// the config which are new, the same target can be given multiple times
+func (gs *GlobalStateType) getConfigChanges(config Config) ([]int, []apiNewTarget) {
+	wanted := make(map[string]int)
+	for _, t := range config.Targets {
+		wanted[t.getKey()]++
+	}
+
+	remove := make([]int, 0)
+	existing := make(map[string]int)
+	for i := range gs.Server {
+		key := gs.Server[i].ConfigKey
+		if key == "" {
+			continue
+		}
+		if existing[key] < wanted[key] {
+			existing[key]++
+			continue
+		}
+		remove = append(remove, gs.Server[i].ID)
+	}
+
+	add := make([]apiNewTarget, 0)
+	for _, t := range config.Targets {
+		key := t.getKey()
+		if existing[key] > 0 {
+			existing[key]--
+			continue
+		}
+		add = append(add, t.apiNewTarget)
+	}
+	return remove, add
+}
+
+// ReloadConfig loads the config file again and applies it, the result is shown in the user interface
+func (gs *GlobalStateType) ReloadConfig(path string) {
+	config, err := LoadConfig(path)
+	added, removed := 0, 0
+	if err == nil {
+		added, removed, err = gs.ApplyConfig(config)
+	}
+
+	// the status is drawn by the user interface
+	gs.ServerLock.Lock()
+	defer gs.ServerLock.Unlock()
+	if err != nil {
+		gs.ConfigStatus = fmt.Sprintf("Could not reload %s: %s", path, err)
+		return
+	}
+	gs.ConfigStatus = fmt.Sprintf("Reloaded %s at %s: %d added, %d removed",
+		path, time.Now().Format("15:04:05"), added, removed)
+}
+
+// watchConfig reloads the config file on SIGHUP and when it has changed
+func watchConfig(ctx context.Context, path string) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(configCheckInterval)
+	defer ticker.Stop()
+
+	lastChange := getConfigChange(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			lastChange = getConfigChange(path)
+			globalState.ReloadConfig(path)
+		case <-ticker.C:
+			change := getConfigChange(path)
+			if change != lastChange {
+				lastChange = change
+				globalState.ReloadConfig(path)
+			}
+		}
+	}
+}
+
+// getConfigChange returns the modification time and size of the file to notice changes
+func getConfigChange(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d %d", info.ModTime().UnixNano(), info.Size())
+}
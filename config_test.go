@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{name: "no settings", json: `{}`},
+		{name: "valid settings", json: `{"interval": "1s", "timeout": "500ms"}`},
+		{name: "minimum interval", json: `{"interval": "10ms"}`},
+		{name: "zero interval", json: `{"interval": "0s"}`, err: "invalid interval, the minimum is 10ms"},
+		{name: "short interval", json: `{"interval": "9ms"}`, err: "invalid interval, the minimum is 10ms"},
+		{name: "invalid interval", json: `{"interval": "1"}`, err: "invalid interval, the minimum is 10ms"},
+		{name: "zero timeout", json: `{"timeout": "0s"}`, err: "invalid timeout"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(test.json), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadConfig(path)
+			if test.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Fatalf("error = %v, want %s", err, test.err)
+			}
+		})
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	waitTime := *WaitTime
+	t.Cleanup(func() { *WaitTime = waitTime })
+
+	gs := InitGlobalStateType()
+	getTargets := func() []string {
+		targets := make([]string, 0, len(gs.Server))
+		for i := range gs.Server {
+			targets = append(targets, fmt.Sprintf("%d %s", gs.Server[i].ID, gs.Server[i].GetName()))
+		}
+		return targets
+	}
+	apply := func(json string) (int, int, error) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(json), 0600); err != nil {
+			t.Fatal(err)
+		}
+		config, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("LoadConfig: %v", err)
+		}
+		return gs.ApplyConfig(config)
+	}
+
+	added, removed, err := apply(`{"targets": ["dns 192.0.2.1", "dns a=192.0.2.2", "dns a=192.0.2.2"]}`)
+	if err != nil || added != 3 || removed != 0 {
+		t.Fatalf("first config: %d added, %d removed, error %v", added, removed, err)
+	}
+	// targets of the prompt or the API are not part of the config
+	if err = gs.AddServer("192.0.2.9", nil, "dns", nil); err != nil {
+		t.Fatal(err)
+	}
+	gs.Server[0].SuccessQueries = 7
+
+	// the same target as object, one of the duplicates and a new target
+	added, removed, err = apply(`{"interval": "2s", "timeout": "300ms", "targets": [
+		{"address": "192.0.2.1", "plugin": "dns"}, "dns a=192.0.2.2", "dns 192.0.2.3 type=AAAA"]}`)
+	if err != nil || added != 1 || removed != 1 {
+		t.Fatalf("second config: %d added, %d removed, error %v", added, removed, err)
+	}
+	want := []string{"1 192.0.2.1:53", "2 [a] 192.0.2.2:53", "4 192.0.2.9:53", "5 192.0.2.3:53"}
+	if got := getTargets(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("targets = %v, want %v", got, want)
+	}
+	if gs.Server[0].SuccessQueries != 7 {
+		t.Errorf("the unchanged target lost its statistics")
+	}
+	if *WaitTime != 2*time.Second || gs.Timeout != 300*time.Millisecond {
+		t.Errorf("interval %s and timeout %s, want 2s and 300ms", *WaitTime, gs.Timeout)
+	}
+	if gs.Server[3].Config["Timeout"] != "300ms" {
+		t.Errorf("the new target has the timeout %s, want the one of the config", gs.Server[3].Config["Timeout"])
+	}
+
+	// an invalid target leaves the settings and the targets untouched
+	_, _, err = apply(`{"interval": "5s", "targets": ["dns 192.0.2.1", "dns 192.0.2.4 type=WRONG"]}`)
+	if err == nil {
+		t.Fatal("the invalid config was applied")
+	}
+	if got := getTargets(); !reflect.DeepEqual(got, want) {
+		t.Errorf("targets after the invalid config = %v, want %v", got, want)
+	}
+	if *WaitTime != 2*time.Second {
+		t.Errorf("interval %s after the invalid config, want 2s", *WaitTime)
+	}
+}
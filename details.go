@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+const (
+	detailResultCount = 10 // amount of raw results that are shown in the detail pane
+	detailGraphHeight = 8  // amount of lines of the latency graph in the detail pane
+)
+
+// detailGraphBlocks are used to draw the latency graph with 8 steps per line
+var detailGraphBlocks = []string{" ", "▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+
+// getDetailPane returns the details of a single Server which are shown below the table
+func getDetailPane(s *Server, width int) string {
+	b := strings.Builder{}
+
+	b.WriteString(fmt.Sprintf("\n  Details: %s (%s)\n", s.GetName(), s.Plugin))
+
+	// Config without passwords
+	public := Plugins.GetPublicConfig(s.Plugin, s.Config)
+	keys := make([]string, 0, len(public))
+	for key := range public {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	config := make([]string, 0, len(keys))
+	for _, key := range keys {
+		config = append(config, key+"="+public[key])
+	}
+	b.WriteString("  Config: " + strings.Join(config, " ") + "\n")
+
+	// Error classes
+	if classes := s.GetErrorClasses(); len(classes) == 0 {
+		b.WriteString("  Errors: none\n")
+	} else {
+		b.WriteString("  Errors: " + formatErrorClasses(classes) + "\n")
+	}
+
+	// Details of the newest result which has some, e.g. the certificate of the TLS check
+	for i := len(s.Answers) - 1; i >= 0; i-- {
+		if len(s.Answers[i].Metadata) > 0 {
+			b.WriteString("  Result: " + formatMetadata(s.Answers[i].Metadata) + "\n")
+			break
+		}
+	}
+
+	// Rolling statistics
+	b.WriteString("\n" + getStatisticsTable(s))
+
+	// Latency graph, the axis labels need 14 chars
+	b.WriteString("\n")
+	for _, line := range getLatencyGraph(s.Answers, width-14, detailGraphHeight) {
+		b.WriteString(line + "\n")
+	}
+
+	// Last raw results, newest first
+	b.WriteString("\n  Last Results:\n")
+	for i := len(s.Answers) - 1; i >= 0 && i >= len(s.Answers)-detailResultCount; i-- {
+		answer := s.Answers[i]
+		if answer.Result {
+			b.WriteString(fmt.Sprintf("    %s  %s  %s %s\n",
+				answer.Time.Format("15:04:05.000"), color.GreenString("%-5s", "ok"), formatDelay(answer.Delay), answer.Message))
+			continue
+		}
+
+		errorClass := answer.ErrorClass
+		if errorClass == "" {
+			errorClass = "error"
+		}
+		b.WriteString(fmt.Sprintf("    %s  %s  %s %s\n",
+			answer.Time.Format("15:04:05.000"), color.RedString("%-5s", "error"), errorClass, answer.Message))
+	}
+
+	return b.String()
+}
+
+// formatMetadata returns the metadata of a result sorted by key, e.g. `cipher="TLS_AES_128_GCM_SHA256" protocol="TLSv1.3"`
+func formatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, fmt.Sprintf("%s=%q", key, metadata[key]))
+	}
+	return strings.Join(values, " ")
+}
+
+// getLatencyGraph returns a bar graph of the latest results with the given size,
+// failed tests are shown as a red "?" at the bottom
+func getLatencyGraph(answers []TestResult, width int, height int) []string {
+	if width > len(answers) {
+		width = len(answers)
+	}
+	if width < 0 {
+		width = 0
+	}
+	answers = answers[len(answers)-width:]
+
+	maximum := time.Duration(0)
+	for _, answer := range answers {
+		if answer.Result && answer.Delay > maximum {
+			maximum = answer.Delay
+		}
+	}
+
+	lines := make([]string, height)
+	steps := len(detailGraphBlocks) - 1
+	for line := 0; line < height; line++ {
+		// axis label at the top and bottom line
+		label := ""
+		switch line {
+		case 0:
+			label = formatDelay(maximum)
+		case height - 1:
+			label = formatDelay(0)
+		}
+
+		b := strings.Builder{}
+		b.WriteString(fmt.Sprintf("  %10s ┤", label))
+
+		// the steps which are below this line
+		below := (height - 1 - line) * steps
+		for _, answer := range answers {
+			if !answer.Result {
+				if line == height-1 {
+					b.WriteString(color.RedString("%s", "?"))
+				} else {
+					b.WriteString(" ")
+				}
+				continue
+			}
+
+			level := 0
+			if maximum > 0 {
+				level = int(float64(answer.Delay) / float64(maximum) * float64(height*steps))
+			}
+			level -= below
+			switch {
+			case level <= 0:
+				b.WriteString(detailGraphBlocks[0])
+			case level >= steps:
+				b.WriteString(detailGraphBlocks[steps])
+			default:
+				b.WriteString(detailGraphBlocks[level])
+			}
+		}
+
+		lines[line] = b.String()
+	}
+
+	return lines
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+const (
+	eventLogLength      = 1000 // amount of kept events, older events are dropped
+	eventPaneHeight     = 8    // amount of events that are shown in the event pane
+	summaryEventsLength = 20   // amount of events that are printed in the exit summary
+)
+
+/*
+ * Event
+ */
+
+// EventState is the state of a Server after an Event
+type EventState string
+
+const (
+	EventStateDown EventState = "down" // the first test of an outage failed
+	EventStateUp   EventState = "up"   // the first test after an outage succeeded
+)
+
+// Event is a state transition of a Server
+type Event struct {
+	Time         time.Time     `json:"time"`
+	ServerID     int           `json:"-"`
+	Target       string        `json:"target"`
+	State        EventState    `json:"state"`
+	ErrorClass   string        `json:"error_class,omitempty"`   // error class of the first failed test of a down event
+	Duration     time.Duration `json:"-"`                       // duration of the outage for an up event
+	DurationMS   float64       `json:"duration_ms,omitempty"`   // Duration for the JSON output
+	FailedProbes int           `json:"failed_probes,omitempty"` // amount of failed tests of the outage for an up event
+}
+
+func (e Event) String() string {
+	if e.State == EventStateDown {
+		errorClass := e.ErrorClass
+		if errorClass == "" {
+			errorClass = "error"
+		}
+		return fmt.Sprintf("%s  %s  %s (%s)", e.Time.Format("15:04:05.000"), color.RedString("%-4s", e.State), e.Target, errorClass)
+	}
+	return fmt.Sprintf("%s  %s  %s after %s, %d failed probes", e.Time.Format("15:04:05.000"),
+		color.GreenString("%-4s", e.State), e.Target, e.Duration.Round(time.Millisecond), e.FailedProbes)
+}
+
+/*
+ * EventLog
+ */
+
+// EventLog contains the newest events of all Server
+type EventLog struct {
+	Offset int // amount of events the event pane is scrolled back
+
+	events []Event
+	mutex  sync.Mutex
+}
+
+// Add appends the event, it is called concurrently by all Server
+func (l *EventLog) Add(e Event) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.events = append(l.events, e)
+	if len(l.events) > eventLogLength {
+		l.events = l.events[len(l.events)-eventLogLength:]
+	}
+	// keep the scrolled back events in place
+	if l.Offset > 0 {
+		l.Offset++
+	}
+}
+
+// GetEvents returns a copy of all events, oldest first
+func (l *EventLog) GetEvents() []Event {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	events := make([]Event, len(l.events))
+	copy(events, l.events)
+	return events
+}
+
+// Scroll scrolls the event pane delta events back (negative) or forward (positive)
+func (l *EventLog) Scroll(delta int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.Offset -= delta
+	if l.Offset > len(l.events)-eventPaneHeight {
+		l.Offset = len(l.events) - eventPaneHeight
+	}
+	if l.Offset < 0 {
+		l.Offset = 0
+	}
+}
+
+// Reset deletes all events
+func (l *EventLog) Reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.events = nil
+	l.Offset = 0
+}
+
+// GetPane returns the event pane which is shown below the table, newest events first
+func (l *EventLog) GetPane() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	events := l.events
+
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("\n  Events: %d", len(events)))
+	if len(events) > eventPaneHeight {
+		b.WriteString(" (PgUp/PgDn: Scroll)")
+	}
+	b.WriteString("\n")
+	if len(events) == 0 {
+		b.WriteString("    none\n")
+	}
+
+	end := len(events) - l.Offset
+	for i := end - 1; i >= 0 && i >= end-eventPaneHeight; i-- {
+		b.WriteString("    " + events[i].String() + "\n")
+	}
+	return b.String()
+}
+
+/*
+ * Exit summary
+ */
+
+// summaryTarget is a single target of the JSON summary
+type summaryTarget struct {
+	Name            string  `json:"name"`
+	Plugin          string  `json:"plugin"`
+	Success         int     `json:"success"`
+	Errors          int     `json:"errors"`
+	ErrorPercentage float64 `json:"error_percentage"`
+	AverageMS       float64 `json:"average_ms"`
+	BestMS          float64 `json:"best_ms"`
+	WorstMS         float64 `json:"worst_ms"`
+	Outages         int     `json:"outages"`
+	DowntimeMS      float64 `json:"downtime_ms"`
+	Down            bool    `json:"down"`
+}
+
+// summary is the JSON summary of a run
+type summary struct {
+	Start   time.Time       `json:"start"`
+	End     time.Time       `json:"end"`
+	Tests   int             `json:"tests"`
+	Targets []summaryTarget `json:"targets"`
+	Events  []Event         `json:"events"`
+}
+
+// getMilliseconds returns the duration as float in ms
+func getMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// getSummary collects the statistics and events of all Server
+func (gs *GlobalStateType) getSummary(start time.Time, end time.Time) summary {
+	sum := summary{
+		Start:   start,
+		End:     end,
+		Tests:   gs.TestCounter,
+		Targets: make([]summaryTarget, 0, len(gs.Server)),
+		Events:  gs.Events.GetEvents(),
+	}
+
+	for i := range gs.Server {
+		s := &gs.Server[i]
+		st := s.GetStatistics(StatisticWindowAll)
+		sum.Targets = append(sum.Targets, summaryTarget{
+			Name:            s.GetName(),
+			Plugin:          s.Plugin,
+			Success:         st.SuccessQueries,
+			Errors:          st.ErrorQueries,
+			ErrorPercentage: st.GetErrorPercentage(),
+			AverageMS:       getMilliseconds(st.AverageDelay),
+			BestMS:          getMilliseconds(st.BestDelay),
+			WorstMS:         getMilliseconds(st.WorstDelay),
+			Outages:         s.Outages,
+			DowntimeMS:      getMilliseconds(s.GetDowntime(end)),
+			Down:            s.Down,
+		})
+	}
+	for i := range sum.Events {
+		sum.Events[i].DurationMS = getMilliseconds(sum.Events[i].Duration)
+	}
+
+	return sum
+}
+
+// WriteJSONSummary writes the statistics and events of all Server as JSON into the file, "-" is stdout
+func (gs *GlobalStateType) WriteJSONSummary(file string, start time.Time, end time.Time) error {
+	data, err := json.MarshalIndent(gs.getSummary(start, end), "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if file == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// PrintSummary prints the statistics and the newest events of all Server after the user interface was closed
+func (gs *GlobalStateType) PrintSummary(start time.Time, end time.Time) {
+	sum := gs.getSummary(start, end)
+
+	fmt.Printf("\nSummary: %d tests within %s\n", sum.Tests, end.Sub(start).Round(time.Second))
+	table := newReportTable([]string{"Target", "Success", "Errors", "Error %", "Average", "Outages", "Downtime"})
+	for _, t := range sum.Targets {
+		downtime := time.Duration(t.DowntimeMS * float64(time.Millisecond)).Round(time.Millisecond).String()
+		if t.Down {
+			downtime += " (down)"
+		}
+		table.Append([]string{
+			t.Name,
+			strconv.Itoa(t.Success),
+			strconv.Itoa(t.Errors),
+			strconv.FormatFloat(t.ErrorPercentage, 'f', 2, 64) + "%",
+			fmt.Sprintf("%.2f ms", t.AverageMS),
+			strconv.Itoa(t.Outages),
+			downtime,
+		})
+	}
+	table.Render()
+
+	if len(sum.Events) == 0 {
+		return
+	}
+	fmt.Println("\nEvents:")
+	if len(sum.Events) > summaryEventsLength {
+		fmt.Printf("  ... %d earlier events\n", len(sum.Events)-summaryEventsLength)
+		sum.Events = sum.Events[len(sum.Events)-summaryEventsLength:]
+	}
+	for _, e := range sum.Events {
+		fmt.Println("  " + e.String())
+	}
+}
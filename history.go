@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+/*
+ * HistoryStyle
+ */
+
+// HistoryStyle defines how the entries of the query history are drawn
+type HistoryStyle int
+
+const (
+	HistoryStyleASCII     HistoryStyle = iota // a char per entry, colored by the HistoryScale
+	HistoryStyleSparkline                     // a block per entry with a height by the HistoryScale
+	HistoryStyleBraille                       // two entries per char with absolute thresholds
+	historyStyleCount                         // amount of history styles, used to cycle through them
+)
+
+var historyStyleNames = []string{"ascii", "sparkline", "braille"}
+
+// ParseHistoryStyle returns the HistoryStyle with the given name
+func ParseHistoryStyle(name string) (HistoryStyle, error) {
+	for i, n := range historyStyleNames {
+		if n == name {
+			return HistoryStyle(i), nil
+		}
+	}
+	return HistoryStyleASCII, errors.New("unknown history style, available: " + strings.Join(historyStyleNames, ", "))
+}
+
+func (h HistoryStyle) String() string {
+	return historyStyleNames[h]
+}
+
+// Next returns the following HistoryStyle to cycle through all of them
+func (h HistoryStyle) Next() HistoryStyle {
+	return (h + 1) % historyStyleCount
+}
+
+// GetEntriesPerChar returns how many entries of the query history are drawn within a single char
+func (h HistoryStyle) GetEntriesPerChar() int {
+	if h == HistoryStyleBraille {
+		return 2
+	}
+	return 1
+}
+
+/*
+ * HistoryRetention
+ */
+
+// HistoryRetention defines how many tests of the query history are kept, independent of the terminal width
+type HistoryRetention struct {
+	Count    int           // amount of kept tests, 0 if the tests are kept by Duration
+	Duration time.Duration // tests newer than this duration are kept
+}
+
+// ParseHistoryRetention parses an amount of tests (e.g. "1000") or a duration (e.g. "1h")
+func ParseHistoryRetention(value string) (HistoryRetention, error) {
+	if count, err := strconv.Atoi(value); err == nil {
+		if count < 1 {
+			return HistoryRetention{}, errors.New("at least one test must be kept")
+		}
+		return HistoryRetention{Count: count}, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return HistoryRetention{}, errors.New("expected an amount of tests or a duration")
+	}
+	return HistoryRetention{Duration: duration}, nil
+}
+
+func (r HistoryRetention) String() string {
+	if r.Count > 0 {
+		return strconv.Itoa(r.Count) + " tests"
+	}
+	return r.Duration.String()
+}
+
+// Trim returns the answers without the oldest tests which exceed the retention
+func (r HistoryRetention) Trim(answers []TestResult) []TestResult {
+	toRemove := 0
+	if r.Count > 0 {
+		toRemove = len(answers) - r.Count
+	} else if len(answers) > 0 {
+		since := answers[len(answers)-1].Time.Add(-r.Duration)
+		for toRemove < len(answers) && !answers[toRemove].Time.After(since) {
+			toRemove++
+		}
+	}
+
+	if toRemove >= 1 {
+		return answers[toRemove:]
+	}
+	return answers
+}
+
+/*
+ * Shared rating levels
+ */
+
+// historyRatingLevels are the upper limits of the delay rating for each color of the query history
+var historyRatingLevels = []float64{0.6, 0.8, 0.9, 0.95, 1}
+
+// historyRatingColors are the colors of the historyRatingLevels
+var historyRatingColors = []func(format string, a ...interface{}) string{
+	color.GreenString,
+	color.CyanString,
+	color.BlueString,
+	color.YellowString,
+	color.MagentaString,
+}
+
+// historyASCIIChars are the chars of the historyRatingLevels for HistoryStyleASCII
+var historyASCIIChars = []string{".", "-", "+", "*", "#"}
+
+/*
+ * Sparkline
+ */
+
+// sparklineBlocks are the blocks of HistoryStyleSparkline from low to high delay
+var sparklineBlocks = []string{"▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+
+// getSparklineHistory returns the query history as blocks with a height by the rating of the scale points
+func getSparklineHistory(answers []TestResult, points []time.Duration) string {
+	history := strings.Builder{}
+	for _, answer := range answers {
+		if !answer.Result {
+			history.WriteString(color.RedString("%s", "?"))
+			continue
+		}
+		history.WriteString(getColoredSparklineBlock(getHistoryDelayRating(answer.Delay, points)))
+	}
+	return history.String()
+}
+
+// getColoredSparklineBlock returns the block for the rating in the color of the ASCII style
+func getColoredSparklineBlock(rating float64) string {
+	block := int(rating*float64(len(sparklineBlocks))+0.999999) - 1
+	if block < 0 {
+		block = 0
+	}
+	if block >= len(sparklineBlocks) {
+		block = len(sparklineBlocks) - 1
+	}
+	return historyRatingColors[getHistoryRatingLevel(rating)]("%s", sparklineBlocks[block])
+}
+
+// getSparklineColorScale returns the legend for HistoryStyleSparkline
+func getSparklineColorScale() string {
+	scale, points := globalState.Scale.GetLegend()
+
+	for i := range sparklineBlocks {
+		rating := float64(i+1) / float64(len(sparklineBlocks))
+		d := getHistoryRatingDelay(rating, points)
+		scale += fmt.Sprintf("%s < %dms ", getColoredSparklineBlock(rating), d.Milliseconds())
+	}
+
+	return scale + color.RedString("%s", "?") + " error"
+}
+
+/*
+ * Braille
+ */
+
+// brailleThresholds are the upper limits of the delay for one to three dots, more is shown with four dots
+var brailleThresholds = []time.Duration{10 * time.Millisecond, 50 * time.Millisecond, 200 * time.Millisecond}
+
+// brailleColors are the colors for one to four dots
+var brailleColors = []func(format string, a ...interface{}) string{
+	color.GreenString,
+	color.CyanString,
+	color.YellowString,
+	color.MagentaString,
+}
+
+// brailleDots contains the dots of the left and right column of a braille char from bottom to top
+var brailleDots = [2][4]rune{
+	{0x40, 0x04, 0x02, 0x01},
+	{0x80, 0x20, 0x10, 0x08},
+}
+
+// getBrailleLevel returns the amount of dots for the delay, 0 for a failed test
+func getBrailleLevel(answer TestResult) int {
+	if !answer.Result {
+		return 0
+	}
+	for i, threshold := range brailleThresholds {
+		if answer.Delay < threshold {
+			return i + 1
+		}
+	}
+	return len(brailleThresholds) + 1
+}
+
+// getBrailleColumn returns the dots of a single column, a failed test is shown as a single top dot
+func getBrailleColumn(column int, level int) rune {
+	if level == 0 {
+		return brailleDots[column][3]
+	}
+
+	dots := rune(0)
+	for i := 0; i < level; i++ {
+		dots |= brailleDots[column][i]
+	}
+	return dots
+}
+
+// getBrailleHistory returns the query history with two entries per char. The first entry is the
+// firstIndex-th test of the Server, so the pairs stay stable while the history moves on.
+func getBrailleHistory(answers []TestResult, firstIndex int) string {
+	history := strings.Builder{}
+
+	i := 0
+	for i < len(answers) {
+		char := rune(0x2800)
+		worst := 1 // the char gets the color of the worst entry
+
+		// the first char has no left entry if the history starts at an odd test
+		column := 0
+		if i == 0 && firstIndex%2 == 1 {
+			column = 1
+		}
+		for ; column < 2 && i < len(answers); column++ {
+			level := getBrailleLevel(answers[i])
+			char |= getBrailleColumn(column, level)
+			if level == 0 || (worst != 0 && level > worst) {
+				worst = level
+			}
+			i++
+		}
+
+		if worst == 0 {
+			history.WriteString(color.RedString("%c", char))
+		} else {
+			history.WriteString(brailleColors[worst-1]("%c", char))
+		}
+	}
+
+	return history.String()
+}
+
+// getBrailleColorScale returns the legend for HistoryStyleBraille
+func getBrailleColorScale() string {
+	scale := "Scale (absolute): "
+
+	for level := 1; level <= len(brailleThresholds)+1; level++ {
+		char := 0x2800 | getBrailleColumn(0, level) | getBrailleColumn(1, level)
+		if level <= len(brailleThresholds) {
+			scale += fmt.Sprintf("%s < %dms ", brailleColors[level-1]("%c", char), brailleThresholds[level-1].Milliseconds())
+		} else {
+			scale += fmt.Sprintf("%s >= %dms ", brailleColors[level-1]("%c", char), brailleThresholds[level-2].Milliseconds())
+		}
+	}
+
+	char := 0x2800 | getBrailleColumn(0, 0) | getBrailleColumn(1, 0)
+	return scale + color.RedString("%c", char) + " error"
+}
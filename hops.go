@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/Anthrazz/parallel-check/plugins"
+)
+
+// mtrMaxHops is the highest TTL that is probed to find the hops on the path to a target
+const mtrMaxHops = 30
+
+// AddHopServers adds one ping Server per hop on the path to the address like mtr, every Server probes its hop with a
+// limited TTL. The path is discovered once before, later changes of the path are visible in the hop column.
+func (gs *GlobalStateType) AddHopServers(ip string, labels []string, testPlugin string, options map[string]string) error {
+	if testPlugin != "ping" {
+		return errors.New("-mtr needs the ping plugin")
+	}
+
+	hops, err := gs.discoverHops(ip, testPlugin, options)
+	if err != nil {
+		return err
+	}
+
+	for ttl := 1; ttl <= hops; ttl++ {
+		hopOptions := map[string]string{"ttl": strconv.Itoa(ttl)}
+		for name, value := range options {
+			hopOptions[name] = value
+		}
+		if err = gs.AddServer(ip, labels, testPlugin, hopOptions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discoverHops probes all TTLs at once and returns the TTL at which the address answered, the highest TTL with an
+// answer is used if the address itself does not answer
+func (gs *GlobalStateType) discoverHops(ip string, testPlugin string, options map[string]string) (int, error) {
+	dataPoints := make([]plugins.DataPointInterface, mtrMaxHops+1)
+	errs := make([]error, mtrMaxHops+1)
+
+	// set up the plugins of all TTLs before the first probe, so an error does not leave probes running
+	hopPlugins := make([]plugins.PluginInterface, mtrMaxHops+1)
+	for ttl := 1; ttl <= mtrMaxHops; ttl++ {
+		hopOptions := map[string]string{"ttl": strconv.Itoa(ttl)}
+		for name, value := range options {
+			hopOptions[name] = value
+		}
+		config, err := getPluginConfig(ip, testPlugin, hopOptions, gs.Timeout)
+		if err != nil {
+			return 0, err
+		}
+		plugin, err := Plugins.GetNewPlugin(testPlugin)
+		if err != nil {
+			return 0, err
+		}
+		if err = plugin.SetConfig(config); err != nil {
+			return 0, err
+		}
+		hopPlugins[ttl] = plugin
+	}
+
+	wg := sync.WaitGroup{}
+	for ttl := 1; ttl <= mtrMaxHops; ttl++ {
+		wg.Add(1)
+		go func(ttl int) {
+			defer wg.Done()
+			dataPoints[ttl], errs[ttl] = hopPlugins[ttl].ExecuteTest()
+		}(ttl)
+	}
+	wg.Wait()
+
+	hops := 0
+	for ttl := 1; ttl <= mtrMaxHops; ttl++ {
+		if errs[ttl] != nil {
+			return 0, errs[ttl]
+		}
+		dataPoint := dataPoints[ttl]
+		// e.g. missing raw sockets
+		if dataPoint.GetErrorClass() == plugins.ErrorClassPlugin {
+			return 0, errors.New(dataPoint.GetMessage())
+		}
+		if !dataPoint.GetResult() {
+			continue
+		}
+		hops = ttl
+		if dataPoint.GetMetadata()["reached"] == "true" {
+			break
+		}
+	}
+
+	if hops == 0 {
+		return 0, fmt.Errorf("no hop on the path to %s answered", ip)
+	}
+	return hops, nil
+}
@@ -8,12 +8,14 @@ import (
 	"os/signal"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/Anthrazz/parallel-check/plugins"
 	"github.com/eiannone/keyboard"
+	"github.com/fatih/color"
 	"github.com/gosuri/uilive"
 	"github.com/miekg/dns"
 	"github.com/olekukonko/tablewriter"
@@ -22,15 +24,25 @@ import (
 
 // Variables to hold command line arguments
 var (
-	Domain            = flag.String("d", "example.com", "dns check: `domain` that should be queried")
-	DomainType        = flag.String("dns-type", "A", "dns check: what for DNS `record type` should be queried?")
-	MaxCount          = flag.Int("c", 0, "exit after `count` tests")
-	WaitTime          = flag.Duration("w", 1*time.Second, "delay between two checks (prefix duration with ms or s)")
-	TimeoutForQueries = flag.Duration("t", 1*time.Second, "timeout for checks (prefix duration with ms or s)")
-	IPv4              = flag.Bool("4", false, "use IPv4")
-	IPv6              = flag.Bool("6", false, "use IPv6")
-
-	PluginToUse string
+	MaxCount              = flag.Int("c", 0, "exit after `count` tests")
+	WaitTime              = flag.Duration("w", 1*time.Second, "delay between two checks (prefix duration with ms or s)")
+	TimeoutForQueries     = flag.Duration("t", 1*time.Second, "timeout for checks (prefix duration with ms or s)")
+	IPv4                  = flag.Bool("4", false, "use IPv4")
+	IPv6                  = flag.Bool("6", false, "use IPv6")
+	PluginDirectory       = flag.String("plugin-dir", "", "register every executable within `directory` as external check plugin")
+	RecordFile            = flag.String("record", "", "append all test results to the recording `file`, see replay")
+	DatabaseFile          = flag.String("db", "", "store all test results in the SQLite `database`, see report")
+	HistoryStyleName      = flag.String("history", "ascii", "`style` of the query history: ascii, sparkline or braille")
+	HistoryRetentionValue = flag.String("retention", "1000", "keep the query history for this amount of tests or `duration` (e.g. 1h)")
+	ConfigFile            = flag.String("config", "", "read targets and settings from the JSON `file`, reloaded on SIGHUP and changes")
+	APIAddress            = flag.String("api", "", "serve the HTTP/JSON API on `address`, e.g. 127.0.0.1:8080")
+	APIToken              = flag.String("api-token", "", "bearer `token` of the API, needed for non-loopback addresses, better set PC_API_TOKEN")
+	JSONFile              = flag.String("json", "", "write the statistics and events as JSON into `file` at exit, - for stdout")
+	MTR                   = flag.Bool("mtr", false, "expand every ping target of the command line into one row per hop on its path like mtr, needs raw sockets")
+	HistoryScaleName      = flag.String("scale", "global", "color `scale` of the query history: global, target, rolling, percentile or fixed[:ms,ms,ms,ms,ms]")
+
+	PluginToUse     string
+	ExternalPlugins stringListFlag // external plugins given as name=command
 
 	globalState GlobalStateType // contains different variables and functions for the global state of the program
 )
@@ -48,11 +60,27 @@ type GlobalStateType struct {
 
 	// Automatically set:
 	Mutex                sync.Mutex
-	WorstResponseDelay   time.Duration // worst response delay over all resolver, dynamically readjusted
-	MaximumHistoryLength int           // Maximum length of the query history, will be readjusted automatically
-	LongestIPLength      int           // how many chars are in the longest DNS resolver IP?
-	Pause                bool          // Set to true to pause the output and tests
-	ResetState           bool          // Set to true to clear history and restart tests
+	ServerLock           sync.Mutex       // protects Server and the settings while results are added, drawn or changed
+	WorstResponseDelay   time.Duration    // worst response delay over all resolver, dynamically readjusted
+	MaximumHistoryLength int              // Maximum length of the query history, will be readjusted automatically
+	LongestIPLength      int              // how many chars are in the longest DNS resolver IP?
+	Pause                bool             // Set to true to pause the output and tests
+	ResetState           bool             // Set to true to clear history and restart tests
+	Sinks                ResultSinks      // Sinks receive all test results, e.g. to record them
+	Replay               *Replay          // Replay is set if a recording is replayed instead of executing tests
+	SelectedServerID     int              // ID of the Server of the selected row within the table
+	ShowDetails          bool             // Set to true to show the detail pane of the selected row
+	View                 TableView        // View contains the sorting and filtering of the table
+	HistoryStyle         HistoryStyle     // HistoryStyle defines how the query history is drawn
+	Scale                HistoryScale     // Scale defines to which delays the query history is compared
+	HistoryRetention     HistoryRetention // HistoryRetention defines how many tests of the query history are kept
+	Alerts               *Alerting        // Alerts checks the alert rules, nil without rules
+	Events               EventLog         // Events contains the outages of all Server
+	ShowEvents           bool             // Set to true to show the event pane
+	Prompt               Prompt           // Prompt is the input to add targets
+	ConfigStatus         string           // result of the last reload of the config file, guarded by the ServerLock
+	lastServerID         int              // last ID that was used for a Server
+	timeoutChanged       bool             // Set to true to set the Timeout on all Server in the next round
 }
 
 // InitGlobalStateType creates a new Global State Type struct with some safe defaults
@@ -63,6 +91,8 @@ func InitGlobalStateType() GlobalStateType {
 		MaximumHistoryLength: 13,
 		LongestIPLength:      len("Server"), // Length of Header "SERVER"
 		Timeout:              *TimeoutForQueries,
+		Scale:                HistoryScale{Thresholds: defaultScaleThresholds},
+		HistoryRetention:     HistoryRetention{Count: 1000},
 	}
 }
 
@@ -74,19 +104,78 @@ func (gs *GlobalStateType) SetWorstResponseDelay(d time.Duration) {
 		gs.WorstResponseDelay = d
 	}
 }
-func (gs *GlobalStateType) AddServer(ip string, testPlugin string) error {
+
+// AddServer adds a Server which is tested with the plugin, options override the plugin
+// options of the command line by their flag names (e.g. "domain"). The ServerLock must not be held, see NewServer.
+func (gs *GlobalStateType) AddServer(ip string, labels []string, testPlugin string, options map[string]string) error {
+	s, err := gs.NewServer(ip, labels, testPlugin, options)
+	if err != nil {
+		return err
+	}
+
+	gs.ServerLock.Lock()
+	defer gs.ServerLock.Unlock()
+	gs.addNewServer(s)
+	return nil
+}
+
+// NewServer creates a Server with a configured test plugin which is not added yet, see addNewServer. The plugin is
+// configured without holding the ServerLock, e.g. an external helper can take seconds to start, so the ServerLock
+// must not be held.
+func (gs *GlobalStateType) NewServer(ip string, labels []string, testPlugin string, options map[string]string) (Server, error) {
+	gs.ServerLock.Lock()
+	timeout := gs.Timeout
+	gs.ServerLock.Unlock()
+
+	return newConfiguredServer(ip, labels, testPlugin, options, timeout)
+}
+
+// newConfiguredServer creates a Server with a configured test plugin, see AddServer. It is not added yet, so
+// multiple Server can be validated before any of them is added with addNewServer.
+func newConfiguredServer(ip string, labels []string, testPlugin string, options map[string]string, timeout time.Duration) (Server, error) {
 	// Create a new server
 	s, err := newServer(testPlugin)
 	if err != nil {
-		return err
+		return s, err
+	}
+	s.Labels = labels
+
+	// Set the Test config
+	pluginConfig, err := getPluginConfig(ip, testPlugin, options, timeout)
+	if err != nil {
+		return s, err
+	}
+	if err = s.TestPlugin.SetConfig(pluginConfig); err != nil {
+		s.Close()
+		return s, err
 	}
+	s.Config = pluginConfig
+	return s, nil
+}
+
+// addNewServer adds a Server of newConfiguredServer and passes it to the sinks, the ServerLock must be held
+func (gs *GlobalStateType) addNewServer(s Server) *Server {
+	gs.appendServer(s)
+	added := &gs.Server[len(gs.Server)-1]
+	gs.Sinks.AddServer(added)
 
-	pluginConfig := plugins.PluginConfig{
-		"IPAddress":  ip,
-		"Domain":     *Domain,
-		"RecordType": *DomainType,
-		"Timeout":    gs.Timeout.String(),
+	// the timeout could have been changed while the plugin was configured
+	if s.Config["Timeout"] != gs.Timeout.String() {
+		gs.UpdateTimeouts()
 	}
+	gs.AutoScaleQueryHistory()
+	return added
+}
+
+// getPluginConfig returns the validated config of a plugin for the address, see AddServer
+func getPluginConfig(ip string, testPlugin string, options map[string]string, timeout time.Duration) (plugins.PluginConfig, error) {
+	// start with the plugin specific options and add the global ones
+	pluginConfig := Plugins.GetConfig(testPlugin)
+	if err := Plugins.SetOptions(testPlugin, pluginConfig, options); err != nil {
+		return nil, err
+	}
+	pluginConfig["IPAddress"] = ip
+	pluginConfig["Timeout"] = timeout.String()
 	if *IPv4 {
 		pluginConfig["IPv4"] = "true"
 	}
@@ -94,56 +183,172 @@ func (gs *GlobalStateType) AddServer(ip string, testPlugin string) error {
 		pluginConfig["IPv6"] = "true"
 	}
 
-	// Set the Test config
-	// TODO: load correct config for the wanted plugin
-	if err = s.TestPlugin.SetConfig(pluginConfig); err != nil {
-		return err
+	if err := plugins.ValidateConfig(Plugins.GetOptions(testPlugin), pluginConfig); err != nil {
+		return nil, err
 	}
+	return pluginConfig, nil
+}
 
-	// and append it
+// AddReplayServer adds a Server which only gets its results from a replayed recording
+func (gs *GlobalStateType) AddReplayServer(testPlugin string, name string) {
+	gs.appendServer(Server{
+		Plugin:       testPlugin,
+		TestPlugin:   &replayCollector{name: name},
+		ErrorClasses: make(map[string]int),
+		Answers:      make([]TestResult, 0),
+	})
+}
+
+// appendServer assigns an ID to the Server and appends it
+func (gs *GlobalStateType) appendServer(s Server) {
+	gs.lastServerID++
+	s.ID = gs.lastServerID
 	gs.Server = append(gs.Server, s)
 
 	// set the length of the longest IP, needed for AutoScaleQueryHistory()
-	if len(s.TestPlugin.GetName()) > gs.LongestIPLength {
-		gs.LongestIPLength = len(s.TestPlugin.GetName())
+	if len(s.GetName()) > gs.LongestIPLength {
+		gs.LongestIPLength = len(s.GetName())
+	}
+
+	if len(gs.Server) == 1 {
+		gs.SelectedServerID = s.ID
+	}
+}
+
+// RemoveServer removes the Server with the ID, the ServerLock must be held
+func (gs *GlobalStateType) RemoveServer(id int) error {
+	index := gs.getServerIndex(id)
+	if index == -1 {
+		return fmt.Errorf("unknown server %d", id)
 	}
 
+	gs.Server[index].Close()
+	gs.Server = append(gs.Server[:index], gs.Server[index+1:]...)
+	if gs.Alerts != nil {
+		gs.Alerts.RemoveServer(id)
+	}
+
+	// the longest name could be removed
+	gs.LongestIPLength = len("Server")
+	for i := range gs.Server {
+		if len(gs.Server[i].GetName()) > gs.LongestIPLength {
+			gs.LongestIPLength = len(gs.Server[i].GetName())
+		}
+	}
+	gs.AutoScaleQueryHistory()
+
+	// select another row if the selected one was removed
+	gs.MoveSelection(0)
 	return nil
 }
 
+// getServerIndex returns the index of the Server with the ID, -1 if it does not exist
+func (gs *GlobalStateType) getServerIndex(id int) int {
+	for i := range gs.Server {
+		if gs.Server[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
 // AutoScaleQueryHistory Sets a new Query History Length if the user rescales the terminal
 func (gs *GlobalStateType) AutoScaleQueryHistory() {
 	// get terminal size and calculate a new size
-	// 87 chars is the table long without the "DNS SERVER" column
+	// 99 chars is the table long without the "DNS SERVER" column
 	size, _ := ts.GetSize()
-	newSize := size.Col() - 87 - gs.LongestIPLength
+	newSize := size.Col() - 99 - gs.LongestIPLength - getMetadataColumnsWidth(getMetadataColumns(gs.Server))
 
 	// Do not scale under 13 history entries because table header "QUERY HISTORY"
 	// is 13 chars long, so we can use the already allocated space
 	if newSize > len("QUERY HISTORY") {
-		gs.MaximumHistoryLength = newSize
+		gs.MaximumHistoryLength = newSize * gs.HistoryStyle.GetEntriesPerChar()
+	}
+}
+
+// ScrollHistory scrolls the query history delta tests back (negative) or forward (positive)
+func (gs *GlobalStateType) ScrollHistory(delta int) {
+	// the longest query history limits how far it can be scrolled back
+	maximum := 0
+	for i := range gs.Server {
+		if length := len(gs.Server[i].Answers) - gs.MaximumHistoryLength; length > maximum {
+			maximum = length
+		}
+	}
+
+	offset := gs.GetHistoryOffset() - delta
+	if offset > maximum {
+		offset = maximum
+	}
+	if offset <= 0 {
+		gs.View.HistoryEnd = 0
+		return
+	}
+	gs.View.HistoryEnd = gs.TestCounter - offset
+}
+
+// GetHistoryOffset returns how many tests the query history is scrolled back
+func (gs *GlobalStateType) GetHistoryOffset() int {
+	if gs.View.HistoryEnd == 0 || gs.View.HistoryEnd > gs.TestCounter {
+		return 0
 	}
+	return gs.TestCounter - gs.View.HistoryEnd
 }
 
-// QueryResolver do execute a Server.ExecuteQuery on all set Server in go routines
+// QueryResolver do execute the tests of all set Server in go routines. The ServerLock is only held while the tested
+// Server are collected and while their results are added, so the Server can be drawn and changed during the tests.
 func (gs *GlobalStateType) QueryResolver() {
+	type test struct {
+		id        int
+		plugin    plugins.PluginInterface
+		dataPoint plugins.DataPointInterface
+		time      time.Time
+	}
+
+	gs.ServerLock.Lock()
 	gs.TestCounter++
+	gs.Sinks.StartRound()
+	if gs.timeoutChanged {
+		for i := range gs.Server {
+			gs.Server[i].TestPlugin.SetTimeout(gs.Timeout)
+		}
+		gs.timeoutChanged = false
+	}
+	tests := make([]test, 0, len(gs.Server))
+	for i := range gs.Server {
+		if !gs.Server[i].Paused {
+			tests = append(tests, test{id: gs.Server[i].ID, plugin: gs.Server[i].TestPlugin})
+		}
+	}
+	// check if the Query History must be rescaled because the terminal size could have changed
+	gs.AutoScaleQueryHistory()
+	gs.ServerLock.Unlock()
 
+	// ask all DNS resolver asynchronous
 	var wg sync.WaitGroup
-	for i := range gs.Server {
+	for i := range tests {
 		wg.Add(1)
-
-		// ask all DNS resolver asynchronous
-		go func(i int) {
+		go func(t *test) {
 			defer wg.Done()
-			gs.Server[i].ExecuteQuery()
-		}(i)
+			dataPoint, err := t.plugin.ExecuteTest()
+			if err == nil {
+				t.dataPoint = dataPoint
+				t.time = time.Now()
+			}
+		}(&tests[i])
 	}
-
-	// While waiting check if the Query History must be rescaled because the terminal size could have changed
-	gs.AutoScaleQueryHistory()
-
 	wg.Wait()
+
+	gs.ServerLock.Lock()
+	defer gs.ServerLock.Unlock()
+	for _, t := range tests {
+		// the Server could have been removed during its test
+		index := gs.getServerIndex(t.id)
+		if t.dataPoint == nil || index == -1 {
+			continue
+		}
+		gs.Server[index].AddTestResult(t.dataPoint, t.time)
+	}
 }
 
 func (gs *GlobalStateType) TogglePause() {
@@ -154,6 +359,32 @@ func (gs *GlobalStateType) TogglePause() {
 	}
 }
 
+// MoveSelection moves the selected row up (negative) or down (positive) within the shown rows
+func (gs *GlobalStateType) MoveSelection(delta int) {
+	rows := gs.View.GetRows(gs.Server)
+	if len(rows) == 0 {
+		return
+	}
+
+	// select the first row if the selected one is not shown, e.g. because of the filter
+	position := -1
+	for i, row := range rows {
+		if gs.Server[row].ID == gs.SelectedServerID {
+			position = i
+		}
+	}
+	if position == -1 {
+		gs.SelectedServerID = gs.Server[rows[0]].ID
+		return
+	}
+
+	position += delta
+	if position < 0 || position >= len(rows) {
+		return
+	}
+	gs.SelectedServerID = gs.Server[rows[position]].ID
+}
+
 func (gs *GlobalStateType) Reset() {
 	for i := range gs.Server {
 		gs.Server[i].Reset()
@@ -161,13 +392,33 @@ func (gs *GlobalStateType) Reset() {
 
 	globalState.TestCounter = 0
 	globalState.WorstResponseDelay = 0
+	globalState.View.HistoryEnd = 0
+	globalState.Events.Reset()
+	if globalState.Alerts != nil {
+		globalState.Alerts.Reset()
+	}
 }
 
-// UpdateTimeouts updates the timeout on each tested instance
+// UpdateTimeouts updates the timeout on each tested instance at the start of the next round, the plugins must not
+// be changed during their tests. The ServerLock must be held.
 func (gs *GlobalStateType) UpdateTimeouts() {
-	for i := range gs.Server {
-		gs.Server[i].TestPlugin.SetTimeout(gs.Timeout)
-	}
+	gs.timeoutChanged = true
+}
+
+/*
+ * stringListFlag
+ */
+
+// stringListFlag is a command line flag that can be given multiple times
+type stringListFlag []string
+
+func (s *stringListFlag) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringListFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
 
 /*
@@ -188,6 +439,12 @@ const (
 	CommandTypeQuit
 )
 
+// tableFaint is the SGR code for faint text, tablewriter has no constant for it
+const tableFaint = 2
+
+// minimumWaitTime is the smallest delay between two checks, the keyboard, the API and the config file do not go below
+const minimumWaitTime = 10 * time.Millisecond
+
 /*************/
 /* Functions */
 /*************/
@@ -211,16 +468,66 @@ func printHelp() {
 	fmt.Println("the results to the terminal.")
 	fmt.Println()
 	fmt.Println("Interactive Keyboard Shortcuts:")
-	fmt.Println("  Q: Quit")
+	fmt.Println("  Q: Quit (Ctrl-C also while a filter or a target is typed)")
 	fmt.Println("  P: Pause")
 	fmt.Println("  R: Reset")
-	fmt.Println("  Arrow Key Up: Increase Wait Time between Checks")
-	fmt.Println("  Arrow Key Down: Decrease Wait Time")
-	fmt.Println("  Arrow Key Left: Decrease Timeout")
-	fmt.Println("  Arrow Key Right: Increase Timeout")
+	fmt.Println("  Arrow Key Up/Down or K/J: Select Row")
+	fmt.Println("  Enter: Show/Hide Details of the selected Row")
+	fmt.Println("  S: Sort by next Column (Shift+S: Reverse Order)")
+	fmt.Println("  /: Filter Rows by Name or Label (Enter: Apply, Escape: Clear)")
+	fmt.Println("  F: Only show failing Rows")
+	fmt.Println("  H: Switch Style of the Query History")
+	fmt.Println("  C: Switch Color Scale of the Query History")
+	fmt.Println("  W: Switch Time Window of the Statistics (all, 1m, 5m, 15m)")
+	fmt.Println("  Arrow Key Left/Right: Scroll the Query History (End: Newest Tests)")
+	fmt.Println("  E: Show/Hide the Event Log of all Outages (PgUp/PgDn: Scroll)")
+	fmt.Println("  A: Add a Target as \"[plugin] [labels=]address [option=value ...]\"")
+	fmt.Println("  N: Add a Copy of the selected Target with other Options")
+	fmt.Println("  D: Disable/Enable the selected Target")
+	fmt.Println("  X: Remove the selected Target")
+	fmt.Println("  +: Increase Wait Time between Checks")
+	fmt.Println("  -: Decrease Wait Time")
+	fmt.Println("  [: Decrease Timeout")
+	fmt.Println("  ]: Increase Timeout")
+	fmt.Println()
+	fmt.Println("A recording of the -record argument can be replayed with:")
+	fmt.Println("  " + os.Args[0] + " replay [-speed <factor>] <recording>")
+	fmt.Println()
+	fmt.Println("Results of the -db argument can be evaluated with:")
+	fmt.Println("  " + os.Args[0] + " report [<arguments>] <database>")
 	fmt.Println()
 	fmt.Println("Arguments:")
-	flag.PrintDefaults()
+	printFlags(func(f *flag.Flag) bool {
+		return !strings.Contains(f.Name, ".")
+	})
+
+	// Only show the options of the selected plugin to keep the help short
+	fmt.Println()
+	fmt.Printf("Options of the %s plugin:\n", PluginToUse)
+	if len(Plugins.GetOptions(PluginToUse)) == 0 {
+		fmt.Println("  none")
+	}
+	printFlags(func(f *flag.Flag) bool {
+		return strings.HasPrefix(f.Name, PluginToUse+".")
+	})
+	fmt.Println()
+	fmt.Printf("Use \"-p <plugin> -help\" to show the options of another plugin. Available: %s\n", Plugins.GetAvailablePlugins())
+}
+
+// printFlags prints the defaults of all command line flags for which filter returns true
+func printFlags(filter func(f *flag.Flag) bool) {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(flag.CommandLine.Output())
+
+	flag.VisitAll(func(f *flag.Flag) {
+		if filter(f) {
+			fs.Var(f.Value, f.Name, f.Usage)
+			// keep the real default and not the already parsed value
+			fs.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+
+	fs.PrintDefaults()
 }
 
 // Configure and parse all command line flags
@@ -230,9 +537,69 @@ func parseFlags() *GlobalStateType {
 
 	gs := InitGlobalStateType()
 
-	// Add DNS server
+	var err error
+	if gs.HistoryStyle, err = ParseHistoryStyle(*HistoryStyleName); err != nil {
+		fmt.Printf("Invalid -history: %s\n", err)
+		os.Exit(1)
+	}
+	if gs.Scale, err = ParseHistoryScale(*HistoryScaleName); err != nil {
+		fmt.Printf("Invalid -scale: %s\n", err)
+		os.Exit(1)
+	}
+	if gs.HistoryRetention, err = ParseHistoryRetention(*HistoryRetentionValue); err != nil {
+		fmt.Printf("Invalid -retention: %s\n", err)
+		os.Exit(1)
+	}
+
+	if gs.Alerts, err = NewAlerting(); err != nil {
+		fmt.Printf("Invalid -alert: %s\n", err)
+		os.Exit(1)
+	}
+
+	if *RecordFile != "" {
+		recorder, err := NewRecorder(*RecordFile)
+		if err != nil {
+			fmt.Printf("Could not open recording: %s\n", err)
+			os.Exit(1)
+		}
+		gs.Sinks = append(gs.Sinks, recorder)
+	}
+	if *DatabaseFile != "" {
+		storage, err := NewStorage(*DatabaseFile, PluginToUse)
+		if err != nil {
+			fmt.Printf("Could not open database: %s\n", err)
+			os.Exit(1)
+		}
+		gs.Sinks = append(gs.Sinks, storage)
+	}
+
+	// Register external plugins, they are only known after the flags are parsed
+	if err := registerExternalPlugins(); err != nil {
+		fmt.Printf("Could not register external plugin: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Add the targets of the config file, its settings override the command line
+	if *ConfigFile != "" {
+		config, err := LoadConfig(*ConfigFile)
+		if err != nil {
+			fmt.Printf("Could not load config: %s\n", err)
+			os.Exit(1)
+		}
+		if _, _, err = gs.ApplyConfig(config); err != nil {
+			fmt.Printf("Could not load config: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Add DNS server, optional with labels as "label1,label2=address"
 	for _, server := range flag.Args() {
-		err := gs.AddServer(server, PluginToUse)
+		address, labels := parseTarget(server)
+		if *MTR {
+			err = gs.AddHopServers(address, labels, PluginToUse, nil)
+		} else {
+			err = gs.AddServer(address, labels, PluginToUse, nil)
+		}
 		if err != nil {
 			fmt.Printf("Could not add server %s: %s\n", server, err)
 			os.Exit(1)
@@ -247,6 +614,15 @@ func parseFlags() *GlobalStateType {
 	return &gs
 }
 
+// parseTarget splits a target of the command line into the address and its labels
+func parseTarget(target string) (string, []string) {
+	parts := strings.SplitN(target, "=", 2)
+	if len(parts) != 2 {
+		return target, nil
+	}
+	return parts[1], strings.Split(parts[0], ",")
+}
+
 // registerPlugins registers all available plugins
 func registerPlugins() {
 	Plugins.Register(
@@ -259,6 +635,56 @@ func registerPlugins() {
 		"ping",
 		&plugins.PingCollector{},
 	)
+	Plugins.Register(
+		"Path MTU",
+		"pmtu",
+		&plugins.PMTUCollector{},
+	)
+	Plugins.Register(
+		"TLS",
+		"tls",
+		&plugins.TLSCollector{},
+	)
+	Plugins.Register(
+		"UDP",
+		"udp",
+		&plugins.UDPCollector{},
+	)
+	Plugins.Register(
+		"NTP",
+		"ntp",
+		&plugins.NTPCollector{},
+	)
+	Plugins.Register(
+		"Banner",
+		"banner",
+		&plugins.BannerCollector{},
+	)
+	Plugins.Register(
+		"gRPC",
+		"grpc",
+		&plugins.GRPCCollector{},
+	)
+	Plugins.Register(
+		"Redis",
+		"redis",
+		&plugins.RedisCollector{},
+	)
+	Plugins.Register(
+		"Memcached",
+		"memcached",
+		&plugins.MemcachedCollector{},
+	)
+	Plugins.Register(
+		"PostgreSQL",
+		"postgres",
+		plugins.NewSQLCollector(plugins.SQLDriverPostgres),
+	)
+	Plugins.Register(
+		"MySQL",
+		"mysql",
+		plugins.NewSQLCollector(plugins.SQLDriverMySQL),
+	)
 
 	flag.StringVar(&PluginToUse,
 		"plugin", "dns",
@@ -268,6 +694,37 @@ func registerPlugins() {
 		"p", "dns",
 		"shorthand for --plugin",
 	)
+	// every plugin gets its own namespaced flags, e.g. -dns.domain
+	Plugins.DefineFlags(flag.CommandLine)
+	// keep the old short flags of the DNS plugin
+	flag.Var(Plugins.GetOptionFlag("dns", "domain"), "d", "shorthand for -dns.`domain`")
+	flag.Var(Plugins.GetOptionFlag("dns", "type"), "dns-type", "shorthand for -dns.`type`")
+
+	flag.Var(&ExternalPlugins,
+		"plugin-exec",
+		"register an external check plugin as `name=command`, can be given multiple times",
+	)
+}
+
+// registerExternalPlugins registers all plugins from the plugin directory and the command line
+func registerExternalPlugins() error {
+	if *PluginDirectory != "" {
+		if err := Plugins.RegisterExternalDirectory(*PluginDirectory); err != nil {
+			return err
+		}
+	}
+
+	for _, plugin := range ExternalPlugins {
+		parts := strings.SplitN(plugin, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid plugin %q, expected name=command", plugin)
+		}
+		if err := Plugins.RegisterExternal(parts[0], parts[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func main() {
@@ -275,11 +732,37 @@ func main() {
 }
 
 func run() int {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		return runReplay(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		return runReport(os.Args[2:])
+	}
+
 	ctx, cancelRoutines := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	defer cancelRoutines()
 
 	registerPlugins()
+	defineAlertFlags()
 	globalState = *parseFlags()
+	if *ConfigFile != "" {
+		go watchConfig(ctx, *ConfigFile)
+	}
+	if *APIAddress != "" {
+		token := *APIToken
+		if token == "" {
+			token = os.Getenv(apiTokenEnv)
+		}
+		if err := StartAPI(*APIAddress, token); err != nil {
+			fmt.Printf("Could not start API: %s\n", err)
+			return 1
+		}
+	}
+	defer func() {
+		if err := globalState.Sinks.Close(); err != nil {
+			fmt.Printf("Could not write results: %s\n", err)
+		}
+	}()
 
 	// Start rendering routine
 	chRender := make(chan Command)
@@ -288,30 +771,48 @@ func run() int {
 	go renderRoutine(ctx, &wgRender, chRender)
 
 	// Start Keyboard listen routine
-	go keyboardRoutine(ctx, cancelRoutines, chRender)
+	var wgKeyboard sync.WaitGroup
+	wgKeyboard.Add(1)
+	go keyboardRoutine(ctx, &wgKeyboard, cancelRoutines, chRender)
 
 	// Clear Console Screen
 	chRender <- Command{Command: CommandTypeClearConsole}
 
 	// Start Main Loop which coordinate queries and rendering
+	start := time.Now()
 	for {
 		select {
 		case <-ctx.Done():
-			// End Rendering Thread
+			// End Rendering Thread, the keyboard must be closed before the summary is printed
 			close(chRender)
 			wgRender.Wait()
+			wgKeyboard.Wait()
+
+			if *JSONFile != "-" {
+				globalState.PrintSummary(start, time.Now())
+			}
+			if *JSONFile != "" {
+				if err := globalState.WriteJSONSummary(*JSONFile, start, time.Now()); err != nil {
+					fmt.Printf("Could not write JSON: %s\n", err)
+					return 1
+				}
+			}
 			return 0
 		default:
 			startLoop := time.Now()
 
 			// reset all stats if needed
+			globalState.ServerLock.Lock()
 			if globalState.ResetState {
 				globalState.Reset()
 				globalState.ResetState = false
 			}
+			paused := globalState.Pause
+			waitTime := *WaitTime
+			globalState.ServerLock.Unlock()
 
 			// execute all tests
-			if !globalState.Pause {
+			if !paused {
 				globalState.QueryResolver()
 			}
 
@@ -320,13 +821,14 @@ func run() int {
 
 			elapsedTimeSinceStart := time.Since(startLoop)
 			// calculate how long the tests in the last frame have taken and only wait up to the max wait time
-			timeToSleep := *WaitTime - elapsedTimeSinceStart
-			if elapsedTimeSinceStart >= *WaitTime {
+			timeToSleep := waitTime - elapsedTimeSinceStart
+			if elapsedTimeSinceStart >= waitTime {
 				timeToSleep = time.Duration(0)
 			}
 
+			// a break would only leave the select, the canceled context ends the loop and prints the summary
 			if end := sleep(timeToSleep); end {
-				break
+				cancelRoutines()
 			}
 		}
 	}
@@ -335,7 +837,8 @@ func run() int {
 // keyboardRoutine does process all keyboard inputs.
 //
 // It gets a reference to the render channel to start a redrawn of the user interface.
-func keyboardRoutine(ctx context.Context, cancelRoutines context.CancelFunc, chRender chan Command) {
+func keyboardRoutine(ctx context.Context, wg *sync.WaitGroup, cancelRoutines context.CancelFunc, chRender chan Command) {
+	defer wg.Done()
 	keysEvents, err := keyboard.GetKeys(3)
 	if err != nil {
 		panic(err)
@@ -354,42 +857,27 @@ func keyboardRoutine(ctx context.Context, cancelRoutines context.CancelFunc, chR
 			}
 			//fmt.Printf("You pressed: rune %q, key %X\r\n", event.Rune, event.Key)
 
-			// Pause
-			if event.Rune == 'p' || event.Rune == 'P' {
-				globalState.TogglePause()
-			}
-			// Quit
-			if event.Rune == 'q' || event.Rune == 'Q' || event.Key == keyboard.KeyCtrlC {
+			// Ctrl-C quits in every mode, Q only outside of the filter and the prompt
+			if event.Key == keyboard.KeyCtrlC {
 				cancelRoutines()
 				return
 			}
-			// Reset - Set Variable to do reset between tests
-			if event.Rune == 'r' || event.Rune == 'R' {
-				globalState.ResetState = true
-			}
 
-			// Arrow Key down -> decrease delay
-			if event.Key == keyboard.KeyArrowDown {
-				if WaitTime.Milliseconds() >= int64(110) {
-					*WaitTime = *WaitTime - (100 * time.Millisecond)
-				}
-			}
-			// Arrow Key up -> increase delay
-			if event.Key == keyboard.KeyArrowUp {
-				*WaitTime = *WaitTime + (100 * time.Millisecond)
+			// While a prompt is typed all keys belong to it, it takes the ServerLock itself. Only this routine opens and
+			// closes the prompt, so it can be checked without the lock.
+			if globalState.Prompt.Active {
+				handlePromptInput(event)
+				chRender <- Command{Command: CommandTypeRenderTable}
+				continue
 			}
 
-			// Arrow Key left -> decrease timeout
-			if event.Key == keyboard.KeyArrowLeft {
-				if globalState.Timeout.Milliseconds() >= int64(110) {
-					globalState.Timeout = globalState.Timeout - (100 * time.Millisecond)
-					globalState.UpdateTimeouts()
-				}
-			}
-			// Arrow Key right -> increase timeout
-			if event.Key == keyboard.KeyArrowRight {
-				globalState.Timeout = globalState.Timeout + (100 * time.Millisecond)
-				globalState.UpdateTimeouts()
+			// the state is shared with the render, the API, the config reload and the replay
+			globalState.ServerLock.Lock()
+			quit := handleKeyboardEvent(event)
+			globalState.ServerLock.Unlock()
+			if quit {
+				cancelRoutines()
+				return
 			}
 
 			// Re-render Table
@@ -398,6 +886,176 @@ func keyboardRoutine(ctx context.Context, cancelRoutines context.CancelFunc, chR
 	}
 }
 
+// handleKeyboardEvent changes the state for a key and returns true to quit, the ServerLock must be held
+func handleKeyboardEvent(event keyboard.KeyEvent) bool {
+	// While the filter is typed all keys belong to it
+	if globalState.View.FilterInput {
+		handleFilterInput(event)
+		return false
+	}
+
+	// Pause
+	if event.Rune == 'p' || event.Rune == 'P' {
+		globalState.TogglePause()
+	}
+	// Quit
+	if event.Rune == 'q' || event.Rune == 'Q' {
+		return true
+	}
+	// Reset - Set Variable to do reset between tests
+	if event.Rune == 'r' || event.Rune == 'R' {
+		globalState.ResetState = true
+	}
+
+	// Arrow Keys up/down or j/k -> select a row
+	if event.Key == keyboard.KeyArrowUp || event.Rune == 'k' {
+		globalState.MoveSelection(-1)
+	}
+	if event.Key == keyboard.KeyArrowDown || event.Rune == 'j' {
+		globalState.MoveSelection(1)
+	}
+	// Enter -> toggle the detail pane of the selected row, Escape -> close it
+	if event.Key == keyboard.KeyEnter {
+		globalState.ShowDetails = !globalState.ShowDetails
+	}
+	if event.Key == keyboard.KeyEsc {
+		globalState.ShowDetails = false
+	}
+
+	// s -> sort by the next column, S -> reverse the sort order
+	if event.Rune == 's' {
+		globalState.View.NextSortColumn()
+	}
+	if event.Rune == 'S' {
+		globalState.View.SortDescending = !globalState.View.SortDescending
+	}
+	// / -> type a filter
+	if event.Rune == '/' {
+		globalState.View.FilterInput = true
+	}
+	// h -> switch the style of the query history
+	if event.Rune == 'h' || event.Rune == 'H' {
+		globalState.HistoryStyle = globalState.HistoryStyle.Next()
+		globalState.AutoScaleQueryHistory()
+	}
+	// c -> switch the color scale of the query history
+	if event.Rune == 'c' || event.Rune == 'C' {
+		globalState.Scale.NextMode()
+	}
+	// Arrow Keys left/right -> scroll the query history by a quarter, End -> follow the newest tests
+	if event.Key == keyboard.KeyArrowLeft {
+		globalState.ScrollHistory(-globalState.MaximumHistoryLength / 4)
+	}
+	if event.Key == keyboard.KeyArrowRight {
+		globalState.ScrollHistory(globalState.MaximumHistoryLength / 4)
+	}
+	if event.Key == keyboard.KeyEnd {
+		globalState.View.HistoryEnd = 0
+	}
+	// e -> show the event pane, PgUp/PgDn -> scroll it
+	if event.Rune == 'e' || event.Rune == 'E' {
+		globalState.ShowEvents = !globalState.ShowEvents
+	}
+	if event.Key == keyboard.KeyPgup {
+		globalState.Events.Scroll(-eventPaneHeight)
+	}
+	if event.Key == keyboard.KeyPgdn {
+		globalState.Events.Scroll(eventPaneHeight)
+	}
+	// w -> switch the time window of the statistics
+	if event.Rune == 'w' || event.Rune == 'W' {
+		globalState.View.Window = globalState.View.Window.Next()
+	}
+	// f -> only show failing rows
+	if event.Rune == 'f' || event.Rune == 'F' {
+		globalState.View.OnlyFailing = !globalState.View.OnlyFailing
+	}
+
+	// +/- and [/] control the speed and position of a replay, the targets of a replay are fixed
+	if globalState.Replay != nil {
+		switch event.Rune {
+		case '+':
+			globalState.Replay.ChangeSpeed(2)
+		case '-':
+			globalState.Replay.ChangeSpeed(0.5)
+		case '[':
+			globalState.Replay.Seek(-replaySeekStep)
+		case ']':
+			globalState.Replay.Seek(replaySeekStep)
+		}
+		return false
+	}
+
+	// a -> add a target, n -> add a copy of the selected target with other options
+	if event.Rune == 'a' || event.Rune == 'A' {
+		globalState.Prompt.Open("Add target", PluginToUse+" ")
+	}
+	if event.Rune == 'n' || event.Rune == 'N' {
+		if selected := globalState.getServerIndex(globalState.SelectedServerID); selected != -1 {
+			globalState.Prompt.OpenCopy(&globalState.Server[selected])
+		}
+	}
+	// d -> disable/enable the selected target, x -> remove it
+	if event.Rune == 'd' || event.Rune == 'D' {
+		if selected := globalState.getServerIndex(globalState.SelectedServerID); selected != -1 {
+			globalState.Server[selected].Paused = !globalState.Server[selected].Paused
+		}
+	}
+	if event.Rune == 'x' || event.Rune == 'X' {
+		_ = globalState.RemoveServer(globalState.SelectedServerID)
+	}
+	// - -> decrease delay
+	if event.Rune == '-' {
+		if *WaitTime-(100*time.Millisecond) >= minimumWaitTime {
+			*WaitTime = *WaitTime - (100 * time.Millisecond)
+		}
+	}
+	// + -> increase delay
+	if event.Rune == '+' {
+		*WaitTime = *WaitTime + (100 * time.Millisecond)
+	}
+
+	// [ -> decrease timeout
+	if event.Rune == '[' {
+		if globalState.Timeout.Milliseconds() >= int64(110) {
+			globalState.Timeout = globalState.Timeout - (100 * time.Millisecond)
+			globalState.UpdateTimeouts()
+		}
+	}
+	// ] -> increase timeout
+	if event.Rune == ']' {
+		globalState.Timeout = globalState.Timeout + (100 * time.Millisecond)
+		globalState.UpdateTimeouts()
+	}
+	return false
+}
+
+// handleFilterInput adds a typed key to the filter of the table, the ServerLock must be held
+func handleFilterInput(event keyboard.KeyEvent) {
+	v := &globalState.View
+
+	switch {
+	case event.Key == keyboard.KeyEnter:
+		v.FilterInput = false
+	case event.Key == keyboard.KeyEsc:
+		v.FilterInput = false
+		v.Filter = ""
+	case event.Key == keyboard.KeyBackspace || event.Key == keyboard.KeyBackspace2:
+		if len(v.Filter) > 0 {
+			runes := []rune(v.Filter)
+			v.Filter = string(runes[:len(runes)-1])
+		}
+	case event.Key == keyboard.KeySpace:
+		v.Filter += " "
+	case event.Rune != 0:
+		v.Filter += string(event.Rune)
+	}
+
+	// the selected row could be hidden now
+	v.Offset = 0
+	globalState.MoveSelection(0)
+}
+
 func renderRoutine(ctx context.Context, wg *sync.WaitGroup, commands <-chan Command) {
 	defer wg.Done()
 	writer := uilive.New()
@@ -415,48 +1073,157 @@ func renderRoutine(ctx context.Context, wg *sync.WaitGroup, commands <-chan Comm
 
 			// Rewrite the whole console output
 			case CommandTypeRenderTable:
+				// the API must not change the Server while they are drawn
+				globalState.ServerLock.Lock()
+				size, _ := ts.GetSize()
+
+				// Print some additional infos below the table
+				footer := &strings.Builder{}
+				if globalState.Prompt.Active {
+					_, _ = fmt.Fprint(footer, globalState.Prompt.String())
+				}
+				_, _ = fmt.Fprintf(footer, "\n%s\n", "  "+getHistoryColorScale())
+				_, _ = fmt.Fprintf(footer, "  Query History: %d Requests / ~%s | Retention: %s",
+					globalState.MaximumHistoryLength,
+					time.Duration(
+						int(*WaitTime+globalState.WorstResponseDelay)*globalState.MaximumHistoryLength,
+					).Round(time.Second),
+					globalState.HistoryRetention,
+				)
+				if offset := globalState.GetHistoryOffset(); offset > 0 {
+					_, _ = fmt.Fprintf(footer, " | Scrolled back %d tests (End: newest)", offset)
+				}
+				_, _ = fmt.Fprint(footer, "\n")
+				if globalState.Replay != nil {
+					_, _ = fmt.Fprintf(footer, "  %s", globalState.Replay.GetStatus())
+				} else {
+					_, _ = fmt.Fprintf(footer, "  Timeout: %s | Delay: %s", globalState.Timeout, *WaitTime)
+				}
+				if globalState.Pause {
+					_, _ = fmt.Fprintf(footer, " | Pause Active\n")
+				} else {
+					_, _ = fmt.Fprintf(footer, "\n")
+				}
+				_, _ = fmt.Fprintf(footer, "  Tests: %s\n", PluginToUse)
+				if globalState.ConfigStatus != "" {
+					_, _ = fmt.Fprintf(footer, "  %s\n", globalState.ConfigStatus)
+				}
+				if globalState.Alerts != nil {
+					if err := globalState.Alerts.GetError(); err != nil {
+						_, _ = fmt.Fprintf(footer, "  %s\n", color.RedString("Alert action failed: %s", err))
+					}
+				}
+				if err := globalState.Sinks.GetError(); err != nil {
+					_, _ = fmt.Fprintf(footer, "  %s\n", color.RedString("Saving the results failed: %s", err))
+				}
+
+				if globalState.ShowEvents {
+					_, _ = fmt.Fprint(footer, globalState.Events.GetPane())
+				}
+				selected := globalState.getServerIndex(globalState.SelectedServerID)
+				if globalState.ShowDetails && selected != -1 {
+					_, _ = fmt.Fprint(footer, getDetailPane(&globalState.Server[selected], size.Col()))
+				}
+
+				// Only show as many rows as fit into the terminal, the table header needs two lines
+				rows := globalState.View.GetRows(globalState.Server)
+				height := size.Row() - strings.Count(footer.String(), "\n") - 3
+				if height < 3 {
+					height = 3
+				}
+				for i, row := range rows {
+					if row == selected {
+						globalState.View.Scroll(i, height)
+					}
+				}
+				first, last := globalState.View.Offset, globalState.View.Offset+height
+				if first > len(rows) {
+					first = len(rows)
+				}
+				if last > len(rows) {
+					last = len(rows)
+				}
+
 				// Rewrite the whole table to allow a down scale of the query history column
+				// plugins like NTP show details of their results in additional columns in front of the query history
+				columns := getMetadataColumns(globalState.Server)
+				header := []string{"Server", "Success", "Errors", "Error %", "Last", "Average", "P95", "Best", "Worst"}
+				for _, column := range columns {
+					header = append(header, column.Header)
+				}
+				header = append(header, "Query History")
+
 				table := tablewriter.NewWriter(writer)
-				table.SetHeader(
-					[]string{"Server", "Success", "Errors", "Error %", "Last", "Average", "Best", "Worst", "Query History"},
-				)
+				table.SetHeader(globalState.View.GetHeader(header))
 				table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
 				table.SetCenterSeparator("|")
 				table.SetColWidth(globalState.LongestIPLength)
-
-				for _, resolver := range globalState.Server {
-
-					table.Append(
-						[]string{
-							resolver.TestPlugin.GetName(),
-							fmt.Sprintf("%d", resolver.SuccessQueries),
-							fmt.Sprintf("%d", resolver.ErrorQueries),
-							strconv.FormatFloat(resolver.GetErrorPercentage(), 'f', 2, 64) + "%",
-							fmt.Sprintf("%.2f ms", float64(resolver.LastDelay/time.Microsecond)/1000),
-							fmt.Sprintf("%.2f ms", float64(resolver.AverageDelay/time.Microsecond)/1000),
-							fmt.Sprintf("%.2f ms", float64(resolver.BestDelay/time.Microsecond)/1000),
-							fmt.Sprintf("%.2f ms", float64(resolver.WorstDelay/time.Microsecond)/1000),
-							resolver.GetQueryHistory(),
-						},
-					)
+				// every row must use a single line to be able to scroll
+				table.SetAutoWrapText(false)
+
+				// the scale of the query history is the same for all rows unless it is per Server
+				scalePoints := globalState.Scale.GetSharedPoints()
+				for _, row := range rows[first:last] {
+					resolver := &globalState.Server[row]
+					stats := resolver.GetStatistics(globalState.View.Window)
+
+					// highlight the name of the selected row
+					colors := []tablewriter.Colors{{}}
+					if row == selected {
+						colors = []tablewriter.Colors{{tablewriter.Bold, tablewriter.UnderlineSingle}}
+					}
+					// paused rows are drawn faint
+					if resolver.Paused {
+						colors[0] = append(colors[0], tableFaint)
+					}
+					// highlight the name of rows with active alerts
+					if globalState.Alerts != nil && globalState.Alerts.IsActive(resolver.ID) {
+						colors[0] = append(colors[0], tablewriter.FgHiRedColor)
+					}
+
+					values := []string{
+						resolver.GetName(),
+						fmt.Sprintf("%d", stats.SuccessQueries),
+						fmt.Sprintf("%d", stats.ErrorQueries),
+						strconv.FormatFloat(stats.GetErrorPercentage(), 'f', 2, 64) + "%",
+						fmt.Sprintf("%.2f ms", float64(stats.LastDelay/time.Microsecond)/1000),
+						fmt.Sprintf("%.2f ms", float64(stats.AverageDelay/time.Microsecond)/1000),
+						fmt.Sprintf("%.2f ms", float64(stats.P95Delay/time.Microsecond)/1000),
+						fmt.Sprintf("%.2f ms", float64(stats.BestDelay/time.Microsecond)/1000),
+						fmt.Sprintf("%.2f ms", float64(stats.WorstDelay/time.Microsecond)/1000),
+					}
+					for _, column := range columns {
+						values = append(values, resolver.GetMetadataValue(column.Key))
+					}
+					values = append(values, resolver.GetQueryHistory(scalePoints))
+
+					table.Rich(values, colors)
 				}
 
 				table.Render()
 
-				// Print some additional infos
-				_, _ = fmt.Fprintf(writer, "\n%s\n", "  "+getHistoryColorScale())
-				_, _ = fmt.Fprintf(writer, "  Query History: %d Requests / ~%s\n", globalState.MaximumHistoryLength,
-					time.Duration(
-						int(*WaitTime+globalState.WorstResponseDelay)*globalState.MaximumHistoryLength,
-					).Round(time.Second),
-				)
-				_, _ = fmt.Fprintf(writer, "  Timeout: %s | Delay: %s", globalState.Timeout, *WaitTime)
-				if globalState.Pause {
-					_, _ = fmt.Fprintf(writer, " | Pause Active\n")
-				} else {
-					_, _ = fmt.Fprintf(writer, "\n")
+				// show that not all rows fit into the terminal or the filter has hidden some
+				status := globalState.View.GetStatus()
+				if len(rows) != len(globalState.Server) || last-first != len(rows) {
+					if status != "" {
+						status += " | "
+					}
+					if len(rows) == 0 {
+						status += fmt.Sprintf("No matching rows (%d total)", len(globalState.Server))
+					} else {
+						status += fmt.Sprintf("Rows %d-%d of %d (%d total)", first+1, last, len(rows), len(globalState.Server))
+					}
 				}
-				_, _ = fmt.Fprintf(writer, "  Tests: %s\n", PluginToUse)
+				if status != "" {
+					_, _ = fmt.Fprintf(writer, "  %s\n", status)
+				}
+
+				_, _ = fmt.Fprint(writer, footer.String())
+				if globalState.Alerts != nil && globalState.Alerts.RingBell() {
+					_, _ = fmt.Fprint(writer, "\a")
+				}
+
+				globalState.ServerLock.Unlock()
 
 				err := writer.Flush()
 				if err != nil {
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// the plugins are registered once like on start, this also defines their flags
+	registerPlugins()
+	os.Exit(m.Run())
+}
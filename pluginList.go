@@ -2,6 +2,11 @@ package main
 
 import (
 	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/Anthrazz/parallel-check/plugins"
 )
@@ -16,16 +21,168 @@ type pluginConfiguration struct {
 	name                string
 	nameCommandlineFlag string
 	Collector           plugins.PluginInterface
+	options             []*optionFlag // values of all plugin specific options
 }
 
 func (p pluginList) Register(name string, commandLineName string, collector plugins.PluginInterface) {
+	options := make([]*optionFlag, 0)
+	for _, option := range collector.GetOptions() {
+		options = append(options, &optionFlag{
+			option: option,
+			value:  option.Default,
+		})
+	}
+
 	Plugins = append(Plugins, pluginConfiguration{
 		name:                name,
 		nameCommandlineFlag: commandLineName,
 		Collector:           collector,
+		options:             options,
 	})
 }
 
+// DefineFlags adds a namespaced command line flag for every option of every registered plugin, e.g. -dns.domain.
+// Secret options get no flag, they would be visible in the process list.
+func (p pluginList) DefineFlags(fs *flag.FlagSet) {
+	for _, plugin := range Plugins {
+		for _, o := range plugin.options {
+			if o.option.Secret {
+				continue
+			}
+			fs.Var(o, o.GetFlagName(plugin.nameCommandlineFlag), o.option.GetUsage())
+		}
+	}
+}
+
+// GetOptionFlag returns the flag of a single plugin option, nil if it does not exist
+func (p pluginList) GetOptionFlag(cmdName string, flagName string) *optionFlag {
+	for _, plugin := range Plugins {
+		if plugin.nameCommandlineFlag != cmdName {
+			continue
+		}
+		for _, o := range plugin.options {
+			if o.option.Flag == flagName {
+				return o
+			}
+		}
+	}
+	return nil
+}
+
+// GetConfig returns the config of all plugin specific options with their current values
+func (p pluginList) GetConfig(cmdName string) plugins.PluginConfig {
+	config := plugins.PluginConfig{}
+	for _, plugin := range Plugins {
+		if plugin.nameCommandlineFlag != cmdName {
+			continue
+		}
+		for _, o := range plugin.options {
+			config[o.option.Name] = o.value
+		}
+	}
+	return config
+}
+
+// SetOptions sets the options of a plugin by their flag names (e.g. "domain") within the config
+func (p pluginList) SetOptions(cmdName string, config plugins.PluginConfig, options map[string]string) error {
+	for flagName, value := range options {
+		o := p.GetOptionFlag(cmdName, flagName)
+		if o == nil {
+			return fmt.Errorf("unknown option %s of plugin %s", flagName, cmdName)
+		}
+		config[o.option.Name] = value
+	}
+	return nil
+}
+
+// GetOptionValues returns the values of all plugin options within the config by their flag names, secret options
+// are left out
+func (p pluginList) GetOptionValues(cmdName string, config plugins.PluginConfig) map[string]string {
+	values := make(map[string]string)
+	for _, option := range p.GetOptions(cmdName) {
+		if value, ok := config[option.Name]; ok && !option.Secret {
+			values[option.Flag] = value
+		}
+	}
+	return values
+}
+
+// GetSecretValues returns the values of the secret options within the config by their flag names, empty values are
+// left out
+func (p pluginList) GetSecretValues(cmdName string, config plugins.PluginConfig) map[string]string {
+	values := make(map[string]string)
+	for _, option := range p.GetOptions(cmdName) {
+		if value := config[option.Name]; value != "" && option.Secret {
+			values[option.Flag] = value
+		}
+	}
+	return values
+}
+
+// GetPublicConfig returns a copy of the config with hidden values of the secret options, e.g. to show it
+func (p pluginList) GetPublicConfig(cmdName string, config plugins.PluginConfig) plugins.PluginConfig {
+	public := make(plugins.PluginConfig, len(config))
+	for key, value := range config {
+		public[key] = value
+	}
+	for _, option := range p.GetOptions(cmdName) {
+		if value, ok := public[option.Name]; ok && option.Secret && value != "" {
+			public[option.Name] = "***"
+		}
+	}
+	return public
+}
+
+// RegisterExternal registers a helper binary as plugin, see plugins.ExternalCollector for the protocol
+func (p pluginList) RegisterExternal(name string, command string) error {
+	if p.IsRegistered(name) {
+		return fmt.Errorf("plugin %s is already registered", name)
+	}
+
+	collector, err := plugins.NewExternalCollector(command)
+	if err != nil {
+		return err
+	}
+
+	p.Register(name, name, collector)
+	return nil
+}
+
+// RegisterExternalDirectory registers every executable file within dir as plugin,
+// the file name without extension is used as plugin name
+func (p pluginList) RegisterExternalDirectory(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.IsDir() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		path := filepath.Join(dir, entry.Name())
+		// quote the path, the command line of the helper is parsed like a shell would do
+		if err = Plugins.RegisterExternal(name, quoteShellWord(path)); err != nil {
+			return fmt.Errorf("could not register %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// IsRegistered returns true if a plugin with the command line name is registered
+func (p pluginList) IsRegistered(cmdName string) bool {
+	for _, plugin := range Plugins {
+		if plugin.nameCommandlineFlag == cmdName {
+			return true
+		}
+	}
+	return false
+}
+
 func (p pluginList) GetNewPlugin(cmdName string) (plugins.PluginInterface, error) {
 	for _, plugin := range Plugins {
 		if plugin.nameCommandlineFlag == cmdName {
@@ -35,9 +192,51 @@ func (p pluginList) GetNewPlugin(cmdName string) (plugins.PluginInterface, error
 	return nil, errors.New("plugin not registered")
 }
 
+// GetOptions returns the description of all options of a plugin
+func (p pluginList) GetOptions(cmdName string) []plugins.Option {
+	for _, plugin := range Plugins {
+		if plugin.nameCommandlineFlag == cmdName {
+			return plugin.Collector.GetOptions()
+		}
+	}
+	return nil
+}
+
 func (p pluginList) GetAvailablePlugins() (s []string) {
 	for _, p := range Plugins {
 		s = append(s, p.nameCommandlineFlag)
 	}
 	return s
 }
+
+/*
+ * optionFlag
+ */
+
+// optionFlag is a command line flag for a single plugin option which is validated on parse
+type optionFlag struct {
+	option plugins.Option
+	value  string
+}
+
+// GetFlagName returns the namespaced name of the flag
+func (o *optionFlag) GetFlagName(pluginName string) string {
+	return pluginName + "." + o.option.Flag
+}
+
+func (o *optionFlag) String() string {
+	return o.value
+}
+
+func (o *optionFlag) Set(value string) error {
+	if err := o.option.Validate(value); err != nil {
+		return err
+	}
+	o.value = value
+	return nil
+}
+
+// IsBoolFlag allows to use boolean options without value, e.g. -dns.tcp
+func (o *optionFlag) IsBoolFlag() bool {
+	return o.option.Type == plugins.OptionTypeBool
+}
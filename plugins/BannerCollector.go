@@ -0,0 +1,278 @@
+package plugins
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// bannerMetadataLength is the amount of chars of the banner that are kept in the metadata
+const bannerMetadataLength = 100
+
+// bannerProtocol describes how the greeting of a protocol looks and which command is sent as no-op
+type bannerProtocol struct {
+	port string                                     // default port of the protocol
+	read func(r *bufio.Reader) (string, error)      // reads and checks the greeting
+	noop func(conn net.Conn, r *bufio.Reader) error // sends a harmless command and checks the answer, nil for none
+	quit string                                     // command to close the session, empty for none
+}
+
+// bannerProtocols are all protocols of the banner check
+var bannerProtocols = map[string]bannerProtocol{
+	"smtp": {
+		port: "25",
+		read: func(r *bufio.Reader) (string, error) { return readSMTPResponse(r, "220") },
+		noop: func(conn net.Conn, r *bufio.Reader) error {
+			return sendBannerCommand(conn, "EHLO parallel-check", func() error {
+				_, err := readSMTPResponse(r, "250")
+				return err
+			})
+		},
+		quit: "QUIT",
+	},
+	"ftp": {
+		port: "21",
+		read: func(r *bufio.Reader) (string, error) { return readSMTPResponse(r, "220") },
+		noop: func(conn net.Conn, r *bufio.Reader) error {
+			return sendBannerCommand(conn, "NOOP", func() error {
+				_, err := readSMTPResponse(r, "200")
+				return err
+			})
+		},
+		quit: "QUIT",
+	},
+	"imap": {
+		port: "143",
+		read: func(r *bufio.Reader) (string, error) {
+			return readBannerLine(r, "* OK", "* PREAUTH")
+		},
+		noop: func(conn net.Conn, r *bufio.Reader) error {
+			return sendBannerCommand(conn, "a1 CAPABILITY", func() error {
+				_, err := readIMAPResponse(r, "a1")
+				return err
+			})
+		},
+		quit: "a2 LOGOUT",
+	},
+	"pop3": {
+		port: "110",
+		read: func(r *bufio.Reader) (string, error) {
+			return readBannerLine(r, "+OK")
+		},
+		// NOOP is only allowed after the login, CAPA works before
+		noop: func(conn net.Conn, r *bufio.Reader) error {
+			return sendBannerCommand(conn, "CAPA", func() error {
+				_, err := readBannerLine(r, "+OK")
+				return err
+			})
+		},
+		quit: "QUIT",
+	},
+	"ssh": {
+		port: "22",
+		read: readSSHBanner,
+	},
+}
+
+// BannerCollector represents a single TCP service whose greeting should be checked
+type BannerCollector struct {
+	timeout  time.Duration
+	address  string // IP or FQDN of the server
+	port     string // Port of the service
+	network  string // 'tcp', 'tcp4' or 'tcp6'
+	protocol string // name of the protocol within bannerProtocols
+	noop     bool   // Set to true to send a no-op command after the greeting
+}
+
+func (b *BannerCollector) New() PluginInterface {
+	return &BannerCollector{}
+}
+
+// SetConfig is used to set a config for this TestPlugin
+// The config is a map of key/value pairs
+func (b *BannerCollector) SetConfig(config map[string]string) error {
+	// Parse IPAddress
+	if _, ok := config["IPAddress"]; !ok {
+		return errors.New("missing IPAddress")
+	}
+	b.address = config["IPAddress"]
+	b.network = getNetwork("tcp", config)
+
+	// Parse Protocol
+	if _, ok := bannerProtocols[config["Protocol"]]; !ok {
+		return errors.New("invalid Protocol")
+	}
+	b.protocol = config["Protocol"]
+
+	// Parse Port, the default depends on the protocol
+	if v, ok := config["Port"]; !ok || v == "" {
+		b.port = bannerProtocols[b.protocol].port
+	} else {
+		b.port = config["Port"]
+	}
+
+	b.noop = config["NoOp"] == "true"
+
+	// Parse Timeout
+	if _, ok := config["Timeout"]; !ok {
+		return errors.New("missing Timeout")
+	}
+	timeout, err := time.ParseDuration(config["Timeout"])
+	if err != nil {
+		return errors.New("invalid Timeout")
+	}
+	b.timeout = timeout
+
+	return nil
+}
+
+// GetOptions returns the config options of the banner check
+func (b *BannerCollector) GetOptions() []Option {
+	return []Option{
+		{
+			Name:        "Protocol",
+			Flag:        "protocol",
+			Type:        OptionTypeEnum,
+			Values:      []string{"smtp", "ssh", "imap", "pop3", "ftp"},
+			Default:     "smtp",
+			Description: "`protocol` whose greeting is expected",
+		},
+		{
+			Name:        "Port",
+			Flag:        "port",
+			Type:        OptionTypeInt,
+			Description: "`port` of the service (default the port of the protocol)",
+		},
+		{
+			Name:        "NoOp",
+			Flag:        "noop",
+			Type:        OptionTypeBool,
+			Default:     "false",
+			Description: "send EHLO (smtp), CAPABILITY (imap), CAPA (pop3) or NOOP (ftp) after the greeting",
+		},
+	}
+}
+
+func (b *BannerCollector) GetName() string {
+	return net.JoinHostPort(b.address, b.port) + " (" + b.protocol + ")"
+}
+
+func (b *BannerCollector) SetTimeout(timeout time.Duration) {
+	b.timeout = timeout
+}
+
+// ExecuteTest connects to the service and measures the time until the greeting was received, including the no-op
+func (b *BannerCollector) ExecuteTest() (DataPointInterface, error) {
+	protocol := bannerProtocols[b.protocol]
+
+	start := time.Now()
+	conn, err := net.DialTimeout(b.network, net.JoinHostPort(b.address, b.port), b.timeout)
+	if err != nil {
+		return &DataPoint{
+			delay:      time.Since(start),
+			result:     false,
+			errorClass: getNetworkErrorClass(err),
+			message:    err.Error(),
+		}, nil
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(start.Add(b.timeout))
+	connected := time.Since(start)
+
+	r := bufio.NewReader(conn)
+	banner, err := protocol.read(r)
+	delay := time.Since(start)
+	if err != nil {
+		return b.getErrorDataPoint(delay, err, "unexpected banner"), nil
+	}
+
+	metadata := map[string]string{
+		"banner":  getBannerPreview(banner),
+		"connect": connected.Round(time.Microsecond).String(),
+	}
+
+	if b.noop && protocol.noop != nil {
+		if err = protocol.noop(conn, r); err != nil {
+			return b.getErrorDataPoint(time.Since(start), err, "unexpected response"), nil
+		}
+		delay = time.Since(start)
+	}
+
+	// close the session politely, the answer is not needed
+	if protocol.quit != "" {
+		_, _ = io.WriteString(conn, protocol.quit+"\r\n")
+	}
+
+	return &DataPoint{
+		delay:    delay,
+		result:   true,
+		metadata: metadata,
+	}, nil
+}
+
+// getErrorDataPoint returns a failed DataPoint, unexpected answers get the protocol error class
+func (b *BannerCollector) getErrorDataPoint(delay time.Duration, err error, protocolErrorClass string) *DataPoint {
+	errorClass := protocolErrorClass
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) {
+		errorClass = getNetworkErrorClass(err)
+	}
+	return &DataPoint{
+		delay:      delay,
+		result:     false,
+		errorClass: errorClass,
+		message:    err.Error(),
+	}
+}
+
+// sendBannerCommand sends the command and reads the answer with the given function
+func sendBannerCommand(conn net.Conn, command string, read func() error) error {
+	if _, err := io.WriteString(conn, command+"\r\n"); err != nil {
+		return err
+	}
+	return read()
+}
+
+// readBannerLine reads a single line and checks that it starts with one of the prefixes
+func readBannerLine(r *bufio.Reader, prefixes ...string) (string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return "", err
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(line, prefix) {
+			return line, nil
+		}
+	}
+	return line, fmt.Errorf("unexpected response %q", line)
+}
+
+// readSSHBanner reads the version line of a SSH 2 server, it may send other lines before
+func readSSHBanner(r *bufio.Reader) (string, error) {
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(line, "SSH-") {
+			continue
+		}
+		// 1.99 is a server that supports both versions
+		if !strings.HasPrefix(line, "SSH-2.0-") && !strings.HasPrefix(line, "SSH-1.99-") {
+			return line, fmt.Errorf("unsupported version %q", line)
+		}
+		return line, nil
+	}
+}
+
+// getBannerPreview returns the start of the banner
+func getBannerPreview(banner string) string {
+	if len(banner) > bannerMetadataLength {
+		return banner[:bannerMetadataLength] + "..."
+	}
+	return banner
+}
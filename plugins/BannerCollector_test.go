@@ -0,0 +1,234 @@
+package plugins
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+// startBannerServer starts a fake service which sends the greeting and answers each received line with the reply of
+// the command, unknown commands and an empty greeting close the connection
+func startBannerServer(t *testing.T, greeting string, replies map[string]string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if greeting == "" {
+					return
+				}
+				if _, err := conn.Write([]byte(greeting)); err != nil {
+					return
+				}
+				r := bufio.NewReader(conn)
+				for {
+					line, err := readLine(r)
+					if err != nil {
+						return
+					}
+					reply, ok := replies[line]
+					if !ok {
+						return
+					}
+					if _, err := conn.Write([]byte(reply)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestBannerCollector(t *testing.T) {
+	tests := []struct {
+		name       string
+		protocol   string
+		noop       bool
+		greeting   string
+		replies    map[string]string
+		result     bool
+		errorClass string
+		banner     string
+	}{
+		{
+			name:     "smtp greeting",
+			protocol: "smtp",
+			greeting: "220 mail.example.com ESMTP\r\n",
+			result:   true,
+			banner:   "220 mail.example.com ESMTP",
+		},
+		{
+			name:     "smtp multiline greeting and EHLO",
+			protocol: "smtp",
+			noop:     true,
+			greeting: "220-mail.example.com ESMTP\r\n220 welcome\r\n",
+			replies:  map[string]string{"EHLO parallel-check": "250-mail.example.com\r\n250-PIPELINING\r\n250 SIZE\r\n"},
+			result:   true,
+			banner:   "220-mail.example.com ESMTP",
+		},
+		{
+			name:       "smtp rejected EHLO",
+			protocol:   "smtp",
+			noop:       true,
+			greeting:   "220 mail.example.com ESMTP\r\n",
+			replies:    map[string]string{"EHLO parallel-check": "502 not implemented\r\n"},
+			result:     false,
+			errorClass: "unexpected response",
+		},
+		{
+			name:       "smtp unavailable",
+			protocol:   "smtp",
+			greeting:   "554 no service\r\n",
+			result:     false,
+			errorClass: "unexpected banner",
+		},
+		{
+			name:     "ftp greeting and NOOP",
+			protocol: "ftp",
+			noop:     true,
+			greeting: "220 ftp ready\r\n",
+			replies:  map[string]string{"NOOP": "200 ok\r\n"},
+			result:   true,
+			banner:   "220 ftp ready",
+		},
+		{
+			name:     "imap greeting and CAPABILITY",
+			protocol: "imap",
+			noop:     true,
+			greeting: "* OK IMAP4rev1 ready\r\n",
+			replies:  map[string]string{"a1 CAPABILITY": "* CAPABILITY IMAP4rev1 STARTTLS\r\na1 OK done\r\n"},
+			result:   true,
+			banner:   "* OK IMAP4rev1 ready",
+		},
+		{
+			name:     "imap preauth",
+			protocol: "imap",
+			greeting: "* PREAUTH logged in\r\n",
+			result:   true,
+			banner:   "* PREAUTH logged in",
+		},
+		{
+			name:       "imap failed CAPABILITY",
+			protocol:   "imap",
+			noop:       true,
+			greeting:   "* OK IMAP4rev1 ready\r\n",
+			replies:    map[string]string{"a1 CAPABILITY": "a1 BAD unknown\r\n"},
+			result:     false,
+			errorClass: "unexpected response",
+		},
+		{
+			name:     "pop3 greeting and CAPA",
+			protocol: "pop3",
+			noop:     true,
+			greeting: "+OK POP3 ready\r\n",
+			replies:  map[string]string{"CAPA": "+OK capabilities\r\n"},
+			result:   true,
+			banner:   "+OK POP3 ready",
+		},
+		{
+			name:       "pop3 error",
+			protocol:   "pop3",
+			greeting:   "-ERR busy\r\n",
+			result:     false,
+			errorClass: "unexpected banner",
+		},
+		{
+			name:     "ssh with leading lines",
+			protocol: "ssh",
+			greeting: "hello\r\nSSH-2.0-OpenSSH_9.6\r\n",
+			result:   true,
+			banner:   "SSH-2.0-OpenSSH_9.6",
+		},
+		{
+			name:     "ssh compatibility version",
+			protocol: "ssh",
+			greeting: "SSH-1.99-old\r\n",
+			result:   true,
+			banner:   "SSH-1.99-old",
+		},
+		{
+			name:       "ssh version 1",
+			protocol:   "ssh",
+			greeting:   "SSH-1.5-ancient\r\n",
+			result:     false,
+			errorClass: "unexpected banner",
+		},
+		{
+			name:       "closed before the greeting",
+			protocol:   "smtp",
+			greeting:   "",
+			result:     false,
+			errorClass: ErrorClassNetwork,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host, port, err := net.SplitHostPort(startBannerServer(t, test.greeting, test.replies))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b := &BannerCollector{}
+			noop := "false"
+			if test.noop {
+				noop = "true"
+			}
+			err = b.SetConfig(map[string]string{
+				"IPAddress": host,
+				"Port":      port,
+				"Protocol":  test.protocol,
+				"NoOp":      noop,
+				"Timeout":   "2s",
+			})
+			if err != nil {
+				t.Fatalf("SetConfig: %v", err)
+			}
+
+			dataPoint, err := b.ExecuteTest()
+			if err != nil {
+				t.Fatalf("ExecuteTest: %v", err)
+			}
+			if dataPoint.GetResult() != test.result {
+				t.Fatalf("result = %v, want %v (%s)", dataPoint.GetResult(), test.result, dataPoint.GetMessage())
+			}
+			if dataPoint.GetErrorClass() != test.errorClass {
+				t.Errorf("error class = %q, want %q", dataPoint.GetErrorClass(), test.errorClass)
+			}
+			if test.result && dataPoint.GetMetadata()["banner"] != test.banner {
+				t.Errorf("banner = %q, want %q", dataPoint.GetMetadata()["banner"], test.banner)
+			}
+		})
+	}
+}
+
+func TestGetBannerPreview(t *testing.T) {
+	long := strings.Repeat("a", bannerMetadataLength+1)
+	tests := []struct {
+		banner string
+		want   string
+	}{
+		{"", ""},
+		{"SSH-2.0-OpenSSH", "SSH-2.0-OpenSSH"},
+		{long[:bannerMetadataLength], long[:bannerMetadataLength]},
+		{long, long[:bannerMetadataLength] + "..."},
+	}
+	for _, test := range tests {
+		if got := getBannerPreview(test.banner); got != test.want {
+			t.Errorf("getBannerPreview(%q) = %q, want %q", test.banner, got, test.want)
+		}
+	}
+}
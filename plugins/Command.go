@@ -69,6 +69,18 @@ func (c *CommandCollector) ExecuteTest() (DataPointInterface, error) {
 		nil
 }
 
+// GetOptions returns the config options of the command check
+func (c *CommandCollector) GetOptions() []Option {
+	return []Option{
+		{
+			Name:        "Command",
+			Flag:        "command",
+			Type:        OptionTypeString,
+			Description: "`command` that should be executed, the exitcode is used as result",
+		},
+	}
+}
+
 func (c *CommandCollector) New() PluginInterface {
 	return &CommandCollector{}
 }
@@ -1,13 +1,21 @@
 package plugins
 
 import (
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"net"
+	"os"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/miekg/dns"
 )
 
+// dnsEDNSBufferSize is the UDP buffer size that is announced with EDNS, the recommendation of the DNS flag day 2020
+const dnsEDNSBufferSize = 1232
+
 // DNSCollector represents a single DNS Server that should be checked
 type DNSCollector struct {
 	timeout       time.Duration
@@ -15,6 +23,14 @@ type DNSCollector struct {
 	ipAddress     string // IP Address of the DNS Resolver, IPv4 or IPv6
 	port          string // Port of the DNS service (default 53)
 	domain        string // domain that should be checked
+	tcp           bool   // Set to true to query over TCP instead of UDP
+
+	nsid         bool              // Set to true to request the name server identifier (NSID)
+	clientSubnet *dns.EDNS0_SUBNET // EDNS Client Subnet that is sent with the query, nil to send none
+	identity     string            // CHAOS TXT record that is queried for the identity of the server, e.g. id.server
+
+	udpAnswers   int // answers over UDP since the config was set
+	udpTruncated int // truncated answers over UDP since the config was set
 }
 
 func (d *DNSCollector) New() PluginInterface {
@@ -31,7 +47,7 @@ func (d *DNSCollector) SetConfig(config map[string]string) error {
 	d.ipAddress = config["IPAddress"]
 
 	// Parse Port of DNS Server
-	if _, ok := config["Port"]; !ok {
+	if v, ok := config["Port"]; !ok || v == "" {
 		d.port = "53" // default port
 	} else {
 		d.port = config["Port"]
@@ -43,6 +59,32 @@ func (d *DNSCollector) SetConfig(config map[string]string) error {
 	}
 	d.domain = config["Domain"]
 
+	// Parse the transport, truncated UDP answers are repeated over TCP
+	d.tcp = config["TCP"] == "true"
+	d.udpAnswers = 0
+	d.udpTruncated = 0
+
+	// Parse the EDNS options and the identity query
+	d.nsid = config["NSID"] == "true"
+	d.clientSubnet = nil
+	if v, ok := config["ClientSubnet"]; ok && v != "" {
+		_, subnet, err := net.ParseCIDR(v)
+		if err != nil {
+			return errors.New("invalid ClientSubnet")
+		}
+		ones, _ := subnet.Mask.Size()
+		d.clientSubnet = &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 2, SourceNetmask: uint8(ones), Address: subnet.IP}
+		if subnet.IP.To4() != nil {
+			d.clientSubnet.Family = 1
+		}
+	}
+	switch config["Identity"] {
+	case "", "id.server", "hostname.bind":
+		d.identity = config["Identity"]
+	default:
+		return errors.New("invalid Identity")
+	}
+
 	// Parse which DNS Record Type should be requested
 	if _, ok := config["RecordType"]; !ok {
 		return errors.New("missing RecordType")
@@ -81,7 +123,81 @@ func (d *DNSCollector) SetConfig(config map[string]string) error {
 	return nil
 }
 
+// GetOptions returns the config options of the DNS check
+func (d *DNSCollector) GetOptions() []Option {
+	return []Option{
+		{
+			Name:        "Domain",
+			Flag:        "domain",
+			Type:        OptionTypeString,
+			Default:     "example.com",
+			Description: "`domain` that should be queried",
+		},
+		{
+			Name:        "RecordType",
+			Flag:        "type",
+			Type:        OptionTypeEnum,
+			Values:      []string{"A", "AAAA", "CNAME", "MX", "NS", "PTR", "SOA", "TXT"},
+			Default:     "A",
+			Description: "what for DNS `record type` should be queried?",
+		},
+		{
+			Name:        "Port",
+			Flag:        "port",
+			Type:        OptionTypeInt,
+			Default:     "53",
+			Description: "`port` of the DNS service",
+		},
+		{
+			Name:        "TCP",
+			Flag:        "tcp",
+			Type:        OptionTypeBool,
+			Default:     "false",
+			Description: "query over TCP instead of UDP",
+		},
+		{
+			Name:        "NSID",
+			Flag:        "nsid",
+			Type:        OptionTypeBool,
+			Default:     "false",
+			Description: "request the name server identifier (NSID) of the answering instance",
+		},
+		{
+			Name:        "ClientSubnet",
+			Flag:        "ecs",
+			Type:        OptionTypeString,
+			Description: "send this EDNS Client `subnet`, e.g. 192.0.2.0/24",
+		},
+		{
+			Name:        "Identity",
+			Flag:        "identity",
+			Type:        OptionTypeEnum,
+			Values:      []string{"id.server", "hostname.bind"},
+			Description: "query this CHAOS TXT `record` for the identity of the answering instance",
+		},
+	}
+}
+
+// GetColumns returns the share of truncated UDP answers and the identity of the answering instance as additional
+// columns, so changes of the anycast site are visible
+func (d *DNSCollector) GetColumns() []Column {
+	columns := make([]Column, 0)
+	if !d.tcp {
+		columns = append(columns, Column{Header: "Truncated", Key: "truncated", Width: len("100.0%")})
+	}
+	if d.nsid {
+		columns = append(columns, Column{Header: "NSID", Key: "nsid", Width: len("fra1.example")})
+	}
+	if d.identity != "" {
+		columns = append(columns, Column{Header: "Identity", Key: "identity", Width: len("fra1.example")})
+	}
+	return columns
+}
+
 func (d *DNSCollector) GetName() string {
+	if d.tcp {
+		return d.ipAddress + ":" + d.port + " (tcp)"
+	}
 	return d.ipAddress + ":" + d.port
 }
 
@@ -89,30 +205,157 @@ func (d *DNSCollector) SetTimeout(timeout time.Duration) {
 	d.timeout = timeout
 }
 
+// ExecuteTest queries the domain over UDP and repeats the query over TCP if the answer is truncated, the delay is
+// the sum of both queries like a client would see it
 func (d *DNSCollector) ExecuteTest() (DataPointInterface, error) {
-	c := dns.Client{}
-	c.Timeout = d.timeout
-
-	// execute the DNS query
+	// execute the DNS query, a TCP fallback and the identity query have to finish within the same timeout
+	deadline := time.Now().Add(d.timeout)
 	m := dns.Msg{}
 	m.SetQuestion(d.domain+".", d.dnsRecordType)
-	r, delay, err := c.Exchange(&m, d.getIP()+":"+d.port)
+	d.setEDNSOptions(&m)
+	metadata := map[string]string{}
+
+	var r *dns.Msg
+	var delay time.Duration
+	var err error
+	if d.tcp {
+		r, delay, err = d.exchange(&m, "tcp", deadline)
+	} else {
+		r, delay, err = d.exchange(&m, "udp", deadline)
+		metadata["udp"] = formatDNSDelay(delay)
+		if err == nil {
+			d.udpAnswers++
+			if r.Truncated {
+				d.udpTruncated++
+				var tcpDelay time.Duration
+				r, tcpDelay, err = d.exchange(&m, "tcp", deadline)
+				delay += tcpDelay
+				metadata["tcp"] = formatDNSDelay(tcpDelay)
+				if err != nil {
+					err = fmt.Errorf("TCP fallback: %w", err)
+				}
+			}
+		}
+		if d.udpAnswers > 0 {
+			metadata["truncated"] = fmt.Sprintf("%.1f%%", float64(d.udpTruncated)/float64(d.udpAnswers)*100)
+		}
+	}
 
 	// error or empty answer
-	if err != nil || len(r.Answer) == 0 {
+	if err != nil {
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: getNetworkErrorClass(err),
+			message:    err.Error(),
+			metadata:   metadata,
+		}, nil
+	}
+	addEDNSMetadata(r, metadata)
+	d.addIdentity(metadata, deadline)
+	if len(r.Answer) == 0 {
+		errorClass := "empty answer"
+		if r.Rcode != dns.RcodeSuccess {
+			errorClass = dns.RcodeToString[r.Rcode]
+		}
 		return &DataPoint{
-			delay:  delay,
-			result: false,
+			delay:      delay,
+			result:     false,
+			errorClass: errorClass,
+			message:    "no records in answer with rcode " + dns.RcodeToString[r.Rcode],
+			metadata:   metadata,
 		}, nil
 	}
 
 	// Correct result
 	return &DataPoint{
-		delay:  delay,
-		result: true,
+		delay:    delay,
+		result:   true,
+		metadata: metadata,
 	}, nil
 }
 
+// setEDNSOptions adds the OPT record with the NSID and ECS options if they are configured
+func (d *DNSCollector) setEDNSOptions(m *dns.Msg) {
+	if !d.nsid && d.clientSubnet == nil {
+		return
+	}
+	m.SetEdns0(dnsEDNSBufferSize, false)
+	opt := m.IsEdns0()
+	if d.nsid {
+		opt.Option = append(opt.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+	}
+	if d.clientSubnet != nil {
+		opt.Option = append(opt.Option, d.clientSubnet)
+	}
+}
+
+// addEDNSMetadata adds the returned NSID and ECS scope of the answer to the metadata
+func addEDNSMetadata(r *dns.Msg, metadata map[string]string) {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return
+	}
+	for _, option := range opt.Option {
+		switch option := option.(type) {
+		case *dns.EDNS0_NSID:
+			// the NSID is binary, most servers send a readable name
+			nsid, err := hex.DecodeString(option.Nsid)
+			if err == nil && strings.IndexFunc(string(nsid), func(r rune) bool { return !unicode.IsPrint(r) }) == -1 {
+				metadata["nsid"] = string(nsid)
+			} else {
+				metadata["nsid"] = option.Nsid
+			}
+		case *dns.EDNS0_SUBNET:
+			metadata["scope"] = fmt.Sprintf("/%d", option.SourceScope)
+		}
+	}
+}
+
+// addIdentity queries the CHAOS TXT record of the identity and adds it to the metadata, the query is not part of the
+// test and only uses the rest of its timeout. It is skipped if the server did not answer and its errors are ignored.
+func (d *DNSCollector) addIdentity(metadata map[string]string, deadline time.Time) {
+	if d.identity == "" {
+		return
+	}
+	m := dns.Msg{}
+	m.SetQuestion(d.identity+".", dns.TypeTXT)
+	m.Question[0].Qclass = dns.ClassCHAOS
+
+	network := "udp"
+	if d.tcp {
+		network = "tcp"
+	}
+	r, _, err := d.exchange(&m, network, deadline)
+	if err == nil && r.Truncated {
+		r, _, err = d.exchange(&m, "tcp", deadline)
+	}
+	if err != nil {
+		return
+	}
+	for _, rr := range r.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			metadata["identity"] = strings.Join(txt.Txt, "")
+			return
+		}
+	}
+}
+
+// exchange sends the query over udp or tcp, the answer has to arrive before the deadline
+func (d *DNSCollector) exchange(m *dns.Msg, network string, deadline time.Time) (*dns.Msg, time.Duration, error) {
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		return nil, 0, os.ErrDeadlineExceeded
+	}
+	c := dns.Client{Net: network, Timeout: timeout}
+	return c.Exchange(m, d.getIP()+":"+d.port)
+}
+
+// formatDNSDelay formats the delay of a single query for the metadata
+func formatDNSDelay(delay time.Duration) string {
+	return fmt.Sprintf("%.2f ms", float64(delay)/float64(time.Millisecond))
+}
+
 // return the IP in a ready-to-use format for the DNS lib
 func (d *DNSCollector) getIP() string {
 	if strings.Contains(d.ipAddress, ":") {
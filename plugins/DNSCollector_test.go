@@ -0,0 +1,305 @@
+package plugins
+
+import (
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// fakeDNSServer answers the queries of a test over UDP and optionally TCP and counts the queries
+type fakeDNSServer struct {
+	answerQuery     bool   // answer the query of the test with an A record, drop it otherwise
+	identity        string // answer of the identity query, empty to drop it
+	tcp             bool   // answer over TCP on the same port too
+	truncateFrom    int32  // UDP answers to the query of the test from this one on are truncated, 0 for none
+	identityQueries atomic.Int32
+	udpQueries      atomic.Int32 // queries of the test over UDP
+	tcpQueries      atomic.Int32 // queries of the test over TCP
+}
+
+// start starts the server and returns its address
+func (f *fakeDNSServer) start(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buffer := make([]byte, dns.MaxMsgSize)
+		for {
+			size, address, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			query := dns.Msg{}
+			if err = query.Unpack(buffer[:size]); err != nil || len(query.Question) != 1 {
+				continue
+			}
+			answer := f.getAnswer(&query, "udp")
+			if answer == nil {
+				continue
+			}
+			data, err := answer.Pack()
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteTo(data, address)
+		}
+	}()
+
+	if f.tcp {
+		listener, err := net.Listen("tcp", conn.LocalAddr().String())
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		t.Cleanup(func() { _ = listener.Close() })
+		go f.serveTCP(listener)
+	}
+
+	return conn.LocalAddr().String()
+}
+
+// serveTCP answers the queries of the TCP connections
+func (f *fakeDNSServer) serveTCP(listener net.Listener) {
+	for {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			conn := &dns.Conn{Conn: c}
+			defer conn.Close()
+			for {
+				query, err := conn.ReadMsg()
+				if err != nil || len(query.Question) != 1 {
+					return
+				}
+				answer := f.getAnswer(query, "tcp")
+				if answer == nil {
+					continue
+				}
+				if err = conn.WriteMsg(answer); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// getAnswer returns the answer to the query received over the network, nil to drop the query
+func (f *fakeDNSServer) getAnswer(query *dns.Msg, network string) *dns.Msg {
+	answer := &dns.Msg{}
+	answer.SetReply(query)
+	if query.Question[0].Qclass == dns.ClassCHAOS {
+		f.identityQueries.Add(1)
+		if f.identity == "" {
+			return nil
+		}
+		answer.Answer = append(answer.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{Name: query.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS},
+			Txt: []string{f.identity},
+		})
+		return answer
+	}
+
+	if network == "tcp" {
+		f.tcpQueries.Add(1)
+	} else if n := f.udpQueries.Add(1); f.truncateFrom > 0 && n >= f.truncateFrom {
+		answer.Truncated = true
+		return answer
+	}
+	if !f.answerQuery {
+		return nil
+	}
+	answer.Answer = append(answer.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: query.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+		A:   net.IPv4(192, 0, 2, 1),
+	})
+	return answer
+}
+
+func TestDNSCollectorIdentity(t *testing.T) {
+	timeout := 300 * time.Millisecond
+	tests := []struct {
+		name            string
+		server          *fakeDNSServer
+		result          bool
+		identity        string
+		identityQueries int32
+	}{
+		{
+			name:            "identity",
+			server:          &fakeDNSServer{answerQuery: true, identity: "fra1"},
+			result:          true,
+			identity:        "fra1",
+			identityQueries: 1,
+		},
+		{
+			name:            "unanswered identity",
+			server:          &fakeDNSServer{answerQuery: true},
+			result:          true,
+			identityQueries: 1,
+		},
+		{
+			name:   "no identity after a failed query",
+			server: &fakeDNSServer{identity: "fra1"},
+			result: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host, port, err := net.SplitHostPort(test.server.start(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := &DNSCollector{}
+			err = d.SetConfig(map[string]string{
+				"IPAddress":  host,
+				"Port":       port,
+				"Domain":     "example.org",
+				"RecordType": "A",
+				"Identity":   "id.server",
+				"Timeout":    timeout.String(),
+			})
+			if err != nil {
+				t.Fatalf("SetConfig: %v", err)
+			}
+
+			start := time.Now()
+			dataPoint, err := d.ExecuteTest()
+			duration := time.Since(start)
+			if err != nil {
+				t.Fatalf("ExecuteTest: %v", err)
+			}
+			if dataPoint.GetResult() != test.result {
+				t.Fatalf("result = %v, want %v (%s)", dataPoint.GetResult(), test.result, dataPoint.GetMessage())
+			}
+			if dataPoint.GetMetadata()["identity"] != test.identity {
+				t.Errorf("identity = %q, want %q", dataPoint.GetMetadata()["identity"], test.identity)
+			}
+			if got := test.server.identityQueries.Load(); got != test.identityQueries {
+				t.Errorf("%d identity queries, want %d", got, test.identityQueries)
+			}
+			// the identity query shares the timeout of the test
+			if duration > timeout+100*time.Millisecond {
+				t.Errorf("test took %s with a timeout of %s", duration, timeout)
+			}
+		})
+	}
+}
+
+func TestDNSCollectorTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		server     *fakeDNSServer
+		tcp        bool
+		tests      int
+		result     bool
+		errorClass string
+		udpQueries int32
+		tcpQueries int32
+		truncated  string // truncated column after the last test, empty if it is not shown
+	}{
+		{
+			name:       "udp",
+			server:     &fakeDNSServer{answerQuery: true, tcp: true},
+			tests:      2,
+			result:     true,
+			udpQueries: 2,
+			truncated:  "0.0%",
+		},
+		{
+			// only the second answer is truncated
+			name:       "tcp fallback",
+			server:     &fakeDNSServer{answerQuery: true, tcp: true, truncateFrom: 2},
+			tests:      2,
+			result:     true,
+			udpQueries: 2,
+			tcpQueries: 1,
+			truncated:  "50.0%",
+		},
+		{
+			name:       "failed tcp fallback",
+			server:     &fakeDNSServer{answerQuery: true, truncateFrom: 1},
+			tests:      1,
+			errorClass: ErrorClassNetwork,
+			udpQueries: 1,
+			truncated:  "100.0%",
+		},
+		{
+			name:       "tcp option",
+			server:     &fakeDNSServer{answerQuery: true, tcp: true},
+			tcp:        true,
+			tests:      2,
+			result:     true,
+			tcpQueries: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host, port, err := net.SplitHostPort(test.server.start(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := &DNSCollector{}
+			err = d.SetConfig(map[string]string{
+				"IPAddress":  host,
+				"Port":       port,
+				"Domain":     "example.org",
+				"RecordType": "A",
+				"TCP":        strconv.FormatBool(test.tcp),
+				"Timeout":    "1s",
+			})
+			if err != nil {
+				t.Fatalf("SetConfig: %v", err)
+			}
+
+			var dataPoint DataPointInterface
+			for i := 0; i < test.tests; i++ {
+				if dataPoint, err = d.ExecuteTest(); err != nil {
+					t.Fatalf("ExecuteTest: %v", err)
+				}
+			}
+			if dataPoint.GetResult() != test.result || dataPoint.GetErrorClass() != test.errorClass {
+				t.Fatalf("result = %v (%q: %s), want %v (%q)", dataPoint.GetResult(), dataPoint.GetErrorClass(),
+					dataPoint.GetMessage(), test.result, test.errorClass)
+			}
+			if udp, tcp := test.server.udpQueries.Load(), test.server.tcpQueries.Load(); udp != test.udpQueries ||
+				tcp != test.tcpQueries {
+				t.Errorf("%d queries over UDP and %d over TCP, want %d and %d", udp, tcp, test.udpQueries,
+					test.tcpQueries)
+			}
+
+			// the delays of both queries are shown after a fallback
+			metadata := dataPoint.GetMetadata()
+			if _, ok := metadata["udp"]; ok == test.tcp {
+				t.Errorf("udp delay in the metadata: %v, want it only without the tcp option", ok)
+			}
+			if _, ok := metadata["tcp"]; ok != (test.server.truncateFrom > 0) {
+				t.Errorf("tcp delay in the metadata: %v, want it only after a fallback", ok)
+			}
+			if metadata["truncated"] != test.truncated {
+				t.Errorf("truncated = %q, want %q", metadata["truncated"], test.truncated)
+			}
+
+			// the truncated column is only shown for queries over UDP
+			hasColumn := false
+			for _, column := range d.GetColumns() {
+				hasColumn = hasColumn || column.Key == "truncated"
+			}
+			if hasColumn == test.tcp {
+				t.Errorf("truncated column: %v, want it only without the tcp option", hasColumn)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-shellwords"
+)
+
+/**
+ExternalCollector executes a test with a long-lived helper binary. This allows
+to use checks that can not be part of this tool, e.g. for internal services.
+
+The helper is started once per tested server and speaks a line based JSON
+protocol over stdin/stdout. Every request is a single JSON object on one line:
+
+	{"id":1,"method":"configure","config":{"IPAddress":"10.0.0.1","Timeout":"1s"}}
+	{"id":2,"method":"test","timeout_ms":1000}
+
+The helper must answer every request with a single line that carries the same id:
+
+	{"id":1,"name":"license server 10.0.0.1"}
+	{"id":2,"success":true,"delay_ms":12.5,"metadata":{"version":"1.2"}}
+	{"id":3,"success":false,"error_class":"refused","error":"license pool empty"}
+
+The name in the configure answer is optional, the IPAddress is used without it.
+An "error" in the configure answer rejects the config. A test answer without
+delay_ms uses the round trip time of the request as delay.
+
+The helper should exit when its stdin is closed.
+*/
+
+// externalStartTimeout is the time a helper has to answer the configure request
+const externalStartTimeout = 5 * time.Second
+
+// externalRequest is a single request that is sent to the helper
+type externalRequest struct {
+	ID        int               `json:"id"`
+	Method    string            `json:"method"`
+	Config    map[string]string `json:"config,omitempty"`
+	TimeoutMs int64             `json:"timeout_ms,omitempty"`
+}
+
+// externalAnswer is a single answer that is read from the helper
+type externalAnswer struct {
+	ID         int               `json:"id"`
+	Name       string            `json:"name"`
+	Success    bool              `json:"success"`
+	DelayMs    *float64          `json:"delay_ms"`
+	ErrorClass string            `json:"error_class"`
+	Error      string            `json:"error"`
+	Metadata   map[string]string `json:"metadata"`
+}
+
+// ExternalCollector represents a single Server that is checked by an external helper binary
+type ExternalCollector struct {
+	command []string          // command line of the helper
+	config  map[string]string // config that is sent to the helper at (re)start
+	name    string
+	timeout time.Duration
+
+	mutex   sync.Mutex // ensures only one request at a time is sent to the helper
+	process *exec.Cmd
+	stdin   io.WriteCloser
+	answers chan externalAnswer // answers read from the helper, closed when the helper exits
+	done    chan struct{}       // closed when the helper is not needed anymore
+	lastID  int
+}
+
+// NewExternalCollector creates a new ExternalCollector which executes the given command line as helper
+func NewExternalCollector(command string) (*ExternalCollector, error) {
+	c, err := shellwords.Parse(command)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse command: %w", err)
+	}
+	if len(c) == 0 {
+		return nil, errors.New("empty command")
+	}
+
+	return &ExternalCollector{command: c}, nil
+}
+
+func (e *ExternalCollector) New() PluginInterface {
+	return &ExternalCollector{command: e.command}
+}
+
+// SetConfig starts the helper and sends the config to it
+func (e *ExternalCollector) SetConfig(config map[string]string) error {
+	if _, ok := config["IPAddress"]; !ok {
+		return errors.New("missing IPAddress")
+	}
+	e.name = config["IPAddress"]
+
+	// Parse Timeout
+	if _, ok := config["Timeout"]; !ok {
+		return errors.New("missing Timeout")
+	}
+	timeout, err := time.ParseDuration(config["Timeout"])
+	if err != nil {
+		return errors.New("invalid Timeout")
+	}
+	e.timeout = timeout
+
+	e.config = config
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.stop()
+	return e.start()
+}
+
+// GetName returns the name that the helper has sent or the address
+func (e *ExternalCollector) GetName() string {
+	return e.name
+}
+
+// GetOptions returns no options, the helper gets the whole config and checks it itself
+func (e *ExternalCollector) GetOptions() []Option {
+	return nil
+}
+
+func (e *ExternalCollector) SetTimeout(timeout time.Duration) {
+	e.timeout = timeout
+}
+
+func (e *ExternalCollector) ExecuteTest() (DataPointInterface, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// restart the helper if it has exited in the meantime
+	if e.process == nil {
+		if err := e.start(); err != nil {
+			return &DataPoint{
+				result:     false,
+				errorClass: ErrorClassPlugin,
+				message:    err.Error(),
+			}, nil
+		}
+	}
+
+	now := time.Now()
+	answer, err := e.request(externalRequest{
+		Method:    "test",
+		TimeoutMs: e.timeout.Milliseconds(),
+	}, e.timeout)
+	delay := time.Since(now)
+
+	if err != nil {
+		errorClass := ErrorClassPlugin
+		if errors.Is(err, errExternalTimeout) {
+			errorClass = ErrorClassTimeout
+		}
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: errorClass,
+			message:    err.Error(),
+		}, nil
+	}
+
+	// prefer the delay that the helper has measured
+	if answer.DelayMs != nil {
+		delay = time.Duration(*answer.DelayMs * float64(time.Millisecond))
+	}
+
+	dataPoint := &DataPoint{
+		delay:    delay,
+		result:   answer.Success,
+		message:  answer.Error,
+		metadata: answer.Metadata,
+	}
+	if !answer.Success {
+		dataPoint.errorClass = answer.ErrorClass
+		if dataPoint.errorClass == "" {
+			dataPoint.errorClass = "error"
+		}
+	}
+
+	return dataPoint, nil
+}
+
+var errExternalTimeout = errors.New("helper did not answer in time")
+
+// start launches the helper and sends the config to it, the mutex must be held
+func (e *ExternalCollector) start() error {
+	cmd := exec.Command(e.command[0], e.command[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("could not start helper: %w", err)
+	}
+
+	answers := make(chan externalAnswer, 8)
+	done := make(chan struct{})
+	go readExternalAnswers(stdout, answers, done, cmd)
+
+	e.process = cmd
+	e.stdin = stdin
+	e.answers = answers
+	e.done = done
+
+	answer, err := e.request(externalRequest{
+		Method: "configure",
+		Config: e.config,
+	}, externalStartTimeout)
+	if err != nil {
+		e.stop()
+		return fmt.Errorf("could not configure helper: %w", err)
+	}
+	if answer.Error != "" {
+		e.stop()
+		return fmt.Errorf("helper rejected config: %s", answer.Error)
+	}
+	if answer.Name != "" {
+		e.name = answer.Name
+	}
+
+	return nil
+}
+
+// Close stops the helper when the Server is removed
+func (e *ExternalCollector) Close() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.stop()
+	return nil
+}
+
+// stop closes the stdin of the helper so it can exit and forgets it, the mutex must be held
+func (e *ExternalCollector) stop() {
+	if e.process == nil {
+		return
+	}
+
+	_ = e.stdin.Close()
+	close(e.done)
+	process := e.process
+	// do not block on a helper that does not exit on its own
+	time.AfterFunc(externalStartTimeout, func() {
+		_ = process.Process.Kill()
+	})
+
+	e.process = nil
+	e.stdin = nil
+	e.answers = nil
+	e.done = nil
+}
+
+// request sends a single request to the helper and waits for the matching answer, the mutex must be held
+func (e *ExternalCollector) request(r externalRequest, timeout time.Duration) (externalAnswer, error) {
+	e.lastID++
+	r.ID = e.lastID
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return externalAnswer{}, err
+	}
+	if _, err = e.stdin.Write(append(line, '\n')); err != nil {
+		e.stop()
+		return externalAnswer{}, fmt.Errorf("could not write to helper: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case answer, ok := <-e.answers:
+			if !ok {
+				e.stop()
+				return externalAnswer{}, errors.New("helper has exited")
+			}
+			// skip late answers of requests that already timed out
+			if answer.ID != r.ID {
+				continue
+			}
+			return answer, nil
+		case <-timer.C:
+			return externalAnswer{}, errExternalTimeout
+		}
+	}
+}
+
+// readExternalAnswers reads all answers of a helper until it exits
+func readExternalAnswers(stdout io.Reader, answers chan<- externalAnswer, done <-chan struct{}, cmd *exec.Cmd) {
+	defer close(answers)
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		answer := externalAnswer{}
+		if err := json.Unmarshal(scanner.Bytes(), &answer); err != nil {
+			// ignore everything that is not an answer, e.g. debug output
+			continue
+		}
+		select {
+		case answers <- answer:
+		case <-done:
+			// nobody is interested in the answers anymore, wait until the helper is gone
+			_, _ = io.Copy(io.Discard, stdout)
+		}
+	}
+
+	_ = cmd.Wait()
+}
@@ -0,0 +1,160 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// externalHelperEnv makes the test binary act as a fake helper, see runFakeExternalHelper
+const externalHelperEnv = "PARALLEL_CHECK_FAKE_HELPER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(externalHelperEnv) != "" {
+		runFakeExternalHelper()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeExternalHelper speaks the helper protocol on stdin/stdout, the Mode of the config selects how it answers
+// the tests:
+//
+//	ok       answers with success
+//	mismatch answers with a wrong id first, then with the right one
+//	silent   never answers
+//	crash    exits on the first test if the CrashFile does not exist yet, answers with success afterwards
+//	reject   rejects the config
+func runFakeExternalHelper() {
+	config := map[string]string{}
+	scanner := bufio.NewScanner(os.Stdin)
+	encoder := json.NewEncoder(os.Stdout)
+	for scanner.Scan() {
+		request := externalRequest{}
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			continue
+		}
+
+		if request.Method == "configure" {
+			config = request.Config
+			if config["Mode"] == "reject" {
+				_ = encoder.Encode(map[string]any{"id": request.ID, "error": "unknown mode"})
+				continue
+			}
+			_ = encoder.Encode(map[string]any{"id": request.ID, "name": "fake " + config["IPAddress"]})
+			continue
+		}
+
+		switch config["Mode"] {
+		case "silent":
+			continue
+		case "crash":
+			if _, err := os.Stat(config["CrashFile"]); err != nil {
+				_ = os.WriteFile(config["CrashFile"], nil, 0600)
+				os.Exit(1)
+			}
+		case "mismatch":
+			_ = encoder.Encode(map[string]any{"id": request.ID + 100, "success": false, "error": "late answer"})
+		}
+		_ = encoder.Encode(map[string]any{
+			"id":       request.ID,
+			"success":  true,
+			"delay_ms": 12.5,
+			"metadata": map[string]string{"timeout_ms": strconv.FormatInt(request.TimeoutMs, 10)},
+		})
+	}
+}
+
+// newFakeExternalCollector starts the test binary as helper with the mode
+func newFakeExternalCollector(t *testing.T, config map[string]string) (*ExternalCollector, error) {
+	t.Helper()
+	t.Setenv(externalHelperEnv, "1")
+
+	e := &ExternalCollector{command: []string{os.Args[0]}}
+	config["IPAddress"] = "192.0.2.1"
+	config["Timeout"] = "300ms"
+	err := e.SetConfig(config)
+	t.Cleanup(func() { _ = e.Close() })
+	return e, err
+}
+
+func TestExternalCollector(t *testing.T) {
+	tests := []struct {
+		mode       string
+		result     bool
+		errorClass string
+		delay      time.Duration
+	}{
+		{mode: "ok", result: true, delay: 12500 * time.Microsecond},
+		{mode: "mismatch", result: true, delay: 12500 * time.Microsecond},
+		{mode: "silent", result: false, errorClass: ErrorClassTimeout},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			e, err := newFakeExternalCollector(t, map[string]string{"Mode": test.mode})
+			if err != nil {
+				t.Fatalf("SetConfig: %v", err)
+			}
+			if e.GetName() != "fake 192.0.2.1" {
+				t.Errorf("name = %q, want the name of the helper", e.GetName())
+			}
+
+			dataPoint, err := e.ExecuteTest()
+			if err != nil {
+				t.Fatalf("ExecuteTest: %v", err)
+			}
+			if dataPoint.GetResult() != test.result || dataPoint.GetErrorClass() != test.errorClass {
+				t.Fatalf("result = %v (%q: %s), want %v (%q)", dataPoint.GetResult(), dataPoint.GetErrorClass(),
+					dataPoint.GetMessage(), test.result, test.errorClass)
+			}
+			if test.result && dataPoint.GetDelay() != test.delay {
+				t.Errorf("delay = %s, want the delay of the helper %s", dataPoint.GetDelay(), test.delay)
+			}
+			if test.result && dataPoint.GetMetadata()["timeout_ms"] != "300" {
+				t.Errorf("metadata = %v, want the timeout of the test in ms", dataPoint.GetMetadata())
+			}
+			// the timeout of the test is kept
+			if !test.result && dataPoint.GetDelay() < 300*time.Millisecond {
+				t.Errorf("delay = %s, want at least the timeout", dataPoint.GetDelay())
+			}
+		})
+	}
+}
+
+func TestExternalCollectorRejectedConfig(t *testing.T) {
+	if _, err := newFakeExternalCollector(t, map[string]string{"Mode": "reject"}); err == nil {
+		t.Fatal("SetConfig succeeded, want the error of the helper")
+	}
+}
+
+func TestExternalCollectorRestart(t *testing.T) {
+	crashFile := filepath.Join(t.TempDir(), "crashed")
+	e, err := newFakeExternalCollector(t, map[string]string{"Mode": "crash", "CrashFile": crashFile})
+	if err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+
+	dataPoint, err := e.ExecuteTest()
+	if err != nil {
+		t.Fatalf("ExecuteTest: %v", err)
+	}
+	if dataPoint.GetResult() || dataPoint.GetErrorClass() != ErrorClassPlugin {
+		t.Fatalf("result = %v (%q), want a plugin error of the exited helper", dataPoint.GetResult(),
+			dataPoint.GetErrorClass())
+	}
+
+	// the next test starts and configures the helper again
+	dataPoint, err = e.ExecuteTest()
+	if err != nil {
+		t.Fatalf("ExecuteTest: %v", err)
+	}
+	if !dataPoint.GetResult() {
+		t.Fatalf("result = false (%q: %s), want a success of the restarted helper", dataPoint.GetErrorClass(),
+			dataPoint.GetMessage())
+	}
+}
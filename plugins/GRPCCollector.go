@@ -0,0 +1,209 @@
+package plugins
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCCollector represents a single gRPC server whose health service should be checked
+type GRPCCollector struct {
+	timeout  time.Duration
+	address  string // IP or FQDN of the server
+	port     string // Port of the gRPC service
+	service  string // name of the checked service, empty for the whole server
+	tls      bool   // Set to true to use TLS instead of plaintext
+	insecure bool   // Set to true to skip the verification of the certificate
+
+	mutex sync.Mutex       // protects the connection between a new config and a test
+	conn  *grpc.ClientConn // connection which is kept between the tests
+}
+
+func (g *GRPCCollector) New() PluginInterface {
+	return &GRPCCollector{}
+}
+
+// SetConfig is used to set a config for this TestPlugin
+// The config is a map of key/value pairs
+func (g *GRPCCollector) SetConfig(config map[string]string) error {
+	// Parse IPAddress
+	if _, ok := config["IPAddress"]; !ok {
+		return errors.New("missing IPAddress")
+	}
+	g.address = config["IPAddress"]
+
+	// Parse Port, gRPC has no well known port
+	if v, ok := config["Port"]; !ok || v == "" {
+		return errors.New("missing Port")
+	}
+	g.port = config["Port"]
+
+	g.service = config["Service"]
+	g.tls = config["TLS"] == "true"
+	g.insecure = config["Insecure"] == "true"
+
+	// Parse Timeout
+	if _, ok := config["Timeout"]; !ok {
+		return errors.New("missing Timeout")
+	}
+	timeout, err := time.ParseDuration(config["Timeout"])
+	if err != nil {
+		return errors.New("invalid Timeout")
+	}
+	g.timeout = timeout
+
+	// the connection is established with the first test and kept afterwards
+	credential := insecure.NewCredentials()
+	if g.tls {
+		credential = credentials.NewTLS(&tls.Config{InsecureSkipVerify: g.insecure})
+	}
+	conn, err := grpc.NewClient(net.JoinHostPort(g.address, g.port), grpc.WithTransportCredentials(credential))
+	if err != nil {
+		return err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.conn != nil {
+		_ = g.conn.Close()
+	}
+	g.conn = conn
+
+	return nil
+}
+
+// GetOptions returns the config options of the gRPC check
+func (g *GRPCCollector) GetOptions() []Option {
+	return []Option{
+		{
+			Name:        "Port",
+			Flag:        "port",
+			Type:        OptionTypeInt,
+			Description: "`port` of the gRPC service",
+		},
+		{
+			Name:        "Service",
+			Flag:        "service",
+			Type:        OptionTypeString,
+			Description: "`service` name which is checked, empty for the whole server",
+		},
+		{
+			Name:        "TLS",
+			Flag:        "tls",
+			Type:        OptionTypeBool,
+			Default:     "false",
+			Description: "use TLS instead of plaintext",
+		},
+		{
+			Name:        "Insecure",
+			Flag:        "insecure",
+			Type:        OptionTypeBool,
+			Default:     "false",
+			Description: "do not verify the certificate of the server",
+		},
+	}
+}
+
+func (g *GRPCCollector) GetName() string {
+	if g.service != "" {
+		return net.JoinHostPort(g.address, g.port) + " (" + g.service + ")"
+	}
+	return net.JoinHostPort(g.address, g.port)
+}
+
+func (g *GRPCCollector) SetTimeout(timeout time.Duration) {
+	g.timeout = timeout
+}
+
+// ExecuteTest calls the Check method of the grpc.health.v1.Health service
+func (g *GRPCCollector) ExecuteTest() (DataPointInterface, error) {
+	g.mutex.Lock()
+	conn := g.conn
+	g.mutex.Unlock()
+	if conn == nil {
+		return nil, errors.New("missing config")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+
+	start := time.Now()
+	response, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: g.service})
+	delay := time.Since(start)
+	if err != nil {
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: getGRPCErrorClass(err),
+			message:    err.Error(),
+		}, nil
+	}
+
+	metadata := map[string]string{
+		"status": response.GetStatus().String(),
+	}
+	switch response.GetStatus() {
+	case healthpb.HealthCheckResponse_SERVING:
+		// Correct result
+		return &DataPoint{
+			delay:    delay,
+			result:   true,
+			metadata: metadata,
+		}, nil
+	case healthpb.HealthCheckResponse_NOT_SERVING:
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: "not serving",
+			message:    "service is not serving",
+			metadata:   metadata,
+		}, nil
+	}
+	return &DataPoint{
+		delay:      delay,
+		result:     false,
+		errorClass: "unknown",
+		message:    "service has status " + response.GetStatus().String(),
+		metadata:   metadata,
+	}, nil
+}
+
+// Close closes the connection to the server
+func (g *GRPCCollector) Close() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.conn == nil {
+		return nil
+	}
+	err := g.conn.Close()
+	g.conn = nil
+	return err
+}
+
+// getGRPCErrorClass returns the error class of a failed RPC
+func getGRPCErrorClass(err error) string {
+	switch status.Code(err) {
+	case codes.DeadlineExceeded:
+		return ErrorClassTimeout
+	case codes.Unavailable:
+		// the connection to the server could not be established or broke
+		return "transport"
+	case codes.NotFound:
+		// the health service does not know the service name
+		return "unknown service"
+	case codes.Unimplemented:
+		return "no health service"
+	}
+	return strings.ToLower(status.Code(err).String())
+}
@@ -0,0 +1,108 @@
+package plugins
+
+import (
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startGRPCServer starts an in-process gRPC server, with the health service and the given service states if
+// statuses is not nil
+func startGRPCServer(t *testing.T, statuses map[string]healthpb.HealthCheckResponse_ServingStatus) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	server := grpc.NewServer()
+	if statuses != nil {
+		healthServer := health.NewServer()
+		for service, status := range statuses {
+			healthServer.SetServingStatus(service, status)
+		}
+		healthpb.RegisterHealthServer(server, healthServer)
+	}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String()
+}
+
+// getClosedTCPAddress returns an address on which no service listens anymore
+func getClosedTCPAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+	return address
+}
+
+func TestGRPCCollector(t *testing.T) {
+	statuses := map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"serving":     healthpb.HealthCheckResponse_SERVING,
+		"not-serving": healthpb.HealthCheckResponse_NOT_SERVING,
+		"unknown":     healthpb.HealthCheckResponse_SERVICE_UNKNOWN,
+	}
+	address := startGRPCServer(t, statuses)
+
+	tests := []struct {
+		name       string
+		address    string
+		service    string
+		result     bool
+		errorClass string
+		status     string
+	}{
+		// the whole server is serving by default
+		{name: "server", address: address, result: true, status: "SERVING"},
+		{name: "serving", address: address, service: "serving", result: true, status: "SERVING"},
+		{
+			name:       "not serving",
+			address:    address,
+			service:    "not-serving",
+			errorClass: "not serving",
+			status:     "NOT_SERVING",
+		},
+		{
+			name:       "other status",
+			address:    address,
+			service:    "unknown",
+			errorClass: "unknown",
+			status:     "SERVICE_UNKNOWN",
+		},
+		{name: "unknown service", address: address, service: "missing", errorClass: "unknown service"},
+		{name: "no health service", address: startGRPCServer(t, nil), errorClass: "no health service"},
+		{name: "closed port", address: getClosedTCPAddress(t), errorClass: "transport"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host, port, _ := net.SplitHostPort(test.address)
+			g := &GRPCCollector{}
+			config := map[string]string{"IPAddress": host, "Port": port, "Service": test.service, "Timeout": "1s"}
+			if err := g.SetConfig(config); err != nil {
+				t.Fatalf("SetConfig: %v", err)
+			}
+			t.Cleanup(func() { _ = g.Close() })
+
+			dataPoint, err := g.ExecuteTest()
+			if err != nil {
+				t.Fatalf("ExecuteTest: %v", err)
+			}
+			if dataPoint.GetResult() != test.result || dataPoint.GetErrorClass() != test.errorClass {
+				t.Fatalf("result = %v (%q: %s), want %v (%q)", dataPoint.GetResult(), dataPoint.GetErrorClass(),
+					dataPoint.GetMessage(), test.result, test.errorClass)
+			}
+			if status := dataPoint.GetMetadata()["status"]; status != test.status {
+				t.Errorf("status = %q, want %q", status, test.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package plugins
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// MemcachedCollector represents a single Memcached server that should be checked with the version command
+type MemcachedCollector struct {
+	timeout time.Duration
+	address string // IP or FQDN of the server
+	port    string // Port of the Memcached service (default 11211)
+	network string // 'tcp', 'tcp4' or 'tcp6'
+}
+
+func (c *MemcachedCollector) New() PluginInterface {
+	return &MemcachedCollector{}
+}
+
+// SetConfig is used to set a config for this TestPlugin
+// The config is a map of key/value pairs
+func (c *MemcachedCollector) SetConfig(config map[string]string) error {
+	// Parse IPAddress
+	if _, ok := config["IPAddress"]; !ok {
+		return errors.New("missing IPAddress")
+	}
+	c.address = config["IPAddress"]
+	c.network = getNetwork("tcp", config)
+
+	// Parse Port of Memcached Server
+	if v, ok := config["Port"]; !ok || v == "" {
+		c.port = "11211" // default port
+	} else {
+		c.port = config["Port"]
+	}
+
+	// Parse Timeout
+	if _, ok := config["Timeout"]; !ok {
+		return errors.New("missing Timeout")
+	}
+	timeout, err := time.ParseDuration(config["Timeout"])
+	if err != nil {
+		return errors.New("invalid Timeout")
+	}
+	c.timeout = timeout
+
+	return nil
+}
+
+// GetOptions returns the config options of the Memcached check
+func (c *MemcachedCollector) GetOptions() []Option {
+	return []Option{
+		{
+			Name:        "Port",
+			Flag:        "port",
+			Type:        OptionTypeInt,
+			Default:     "11211",
+			Description: "`port` of the Memcached service",
+		},
+	}
+}
+
+func (c *MemcachedCollector) GetName() string {
+	return net.JoinHostPort(c.address, c.port)
+}
+
+func (c *MemcachedCollector) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// ExecuteTest connects to the server and requests its version
+func (c *MemcachedCollector) ExecuteTest() (DataPointInterface, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout(c.network, net.JoinHostPort(c.address, c.port), c.timeout)
+	if err != nil {
+		return &DataPoint{
+			delay:      time.Since(start),
+			result:     false,
+			errorClass: getNetworkErrorClass(err),
+			message:    err.Error(),
+		}, nil
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(start.Add(c.timeout))
+
+	_, err = io.WriteString(conn, "version\r\n")
+	reply := ""
+	if err == nil {
+		reply, err = readLine(bufio.NewReader(conn))
+	}
+	delay := time.Since(start)
+	if err != nil {
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: getNetworkErrorClass(err),
+			message:    err.Error(),
+		}, nil
+	}
+
+	if !strings.HasPrefix(reply, "VERSION ") {
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: "unexpected reply",
+			message:    reply,
+		}, nil
+	}
+
+	// Correct result
+	return &DataPoint{
+		delay:    delay,
+		result:   true,
+		metadata: map[string]string{"version": strings.TrimPrefix(reply, "VERSION ")},
+	}, nil
+}
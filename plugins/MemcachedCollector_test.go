@@ -0,0 +1,74 @@
+package plugins
+
+import (
+	"bufio"
+	"net"
+	"testing"
+)
+
+// startMemcachedServer starts a fake Memcached server which answers the version command with the reply, an empty
+// reply closes the connection
+func startMemcachedServer(t *testing.T, reply string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, err := readLine(bufio.NewReader(conn))
+				if err != nil || line != "version" || reply == "" {
+					return
+				}
+				_, _ = conn.Write([]byte(reply))
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestMemcachedCollector(t *testing.T) {
+	tests := []struct {
+		name       string
+		address    string
+		result     bool
+		errorClass string
+		version    string
+	}{
+		{name: "version", address: startMemcachedServer(t, "VERSION 1.6.21\r\n"), result: true, version: "1.6.21"},
+		{name: "error reply", address: startMemcachedServer(t, "ERROR\r\n"), errorClass: "unexpected reply"},
+		{name: "closed connection", address: startMemcachedServer(t, ""), errorClass: ErrorClassNetwork},
+		{name: "closed port", address: getClosedTCPAddress(t), errorClass: ErrorClassNetwork},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host, port, _ := net.SplitHostPort(test.address)
+			c := &MemcachedCollector{}
+			if err := c.SetConfig(map[string]string{"IPAddress": host, "Port": port, "Timeout": "1s"}); err != nil {
+				t.Fatalf("SetConfig: %v", err)
+			}
+
+			dataPoint, err := c.ExecuteTest()
+			if err != nil {
+				t.Fatalf("ExecuteTest: %v", err)
+			}
+			if dataPoint.GetResult() != test.result || dataPoint.GetErrorClass() != test.errorClass {
+				t.Fatalf("result = %v (%q: %s), want %v (%q)", dataPoint.GetResult(), dataPoint.GetErrorClass(),
+					dataPoint.GetMessage(), test.result, test.errorClass)
+			}
+			if version := dataPoint.GetMetadata()["version"]; version != test.version {
+				t.Errorf("version = %q, want %q", version, test.version)
+			}
+		})
+	}
+}
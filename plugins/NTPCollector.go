@@ -0,0 +1,228 @@
+package plugins
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	ntpPacketSize = 48         // size of a SNTP packet without extensions
+	ntpEpochDelta = 2208988800 // seconds between the NTP epoch 1900 and the unix epoch 1970
+	ntpLeapAlarm  = 3          // leap indicator of an unsynchronised server
+	ntpMaxStratum = 16         // stratum of an unsynchronised server
+	ntpModeClient = 3          // mode of the request
+	ntpModeServer = 4          // mode of the answer
+	ntpVersion    = 4          // NTP version of the request
+	ntpRequest    = ntpVersion<<3 | ntpModeClient
+)
+
+// ntpLeapIndicators are readable names of the leap indicator
+var ntpLeapIndicators = []string{"none", "insert", "delete", "unsynchronised"}
+
+// NTPCollector represents a single NTP server that should be checked
+type NTPCollector struct {
+	timeout   time.Duration
+	address   string        // IP or FQDN of the NTP server
+	port      string        // Port of the NTP service (default 123)
+	network   string        // 'udp', 'udp4' or 'udp6'
+	maxOffset time.Duration // tests with a higher absolute clock offset fail, 0 to disable
+}
+
+func (n *NTPCollector) New() PluginInterface {
+	return &NTPCollector{}
+}
+
+// SetConfig is used to set a config for this TestPlugin
+// The config is a map of key/value pairs
+func (n *NTPCollector) SetConfig(config map[string]string) error {
+	// Parse IPAddress
+	if _, ok := config["IPAddress"]; !ok {
+		return errors.New("missing IPAddress")
+	}
+	n.address = config["IPAddress"]
+	n.network = getNetwork("udp", config)
+
+	// Parse Port of NTP Server
+	if v, ok := config["Port"]; !ok || v == "" {
+		n.port = "123" // default port
+	} else {
+		n.port = config["Port"]
+	}
+
+	// Parse maximal offset
+	n.maxOffset = 0
+	if v, ok := config["MaxOffset"]; ok && v != "" {
+		maxOffset, err := time.ParseDuration(v)
+		if err != nil || maxOffset < 0 {
+			return errors.New("invalid MaxOffset")
+		}
+		n.maxOffset = maxOffset
+	}
+
+	// Parse Timeout
+	if _, ok := config["Timeout"]; !ok {
+		return errors.New("missing Timeout")
+	}
+	timeout, err := time.ParseDuration(config["Timeout"])
+	if err != nil {
+		return errors.New("invalid Timeout")
+	}
+	n.timeout = timeout
+
+	return nil
+}
+
+// GetOptions returns the config options of the NTP check
+func (n *NTPCollector) GetOptions() []Option {
+	return []Option{
+		{
+			Name:        "Port",
+			Flag:        "port",
+			Type:        OptionTypeInt,
+			Default:     "123",
+			Description: "`port` of the NTP service",
+		},
+		{
+			Name:        "MaxOffset",
+			Flag:        "max-offset",
+			Type:        OptionTypeDuration,
+			Default:     "1s",
+			Description: "tests with a higher clock offset fail, 0 to disable (`duration`)",
+		},
+	}
+}
+
+// GetColumns returns the additional table columns of the NTP check
+func (n *NTPCollector) GetColumns() []Column {
+	return []Column{
+		{Header: "Offset", Key: "offset", Width: len("+123.45 ms")},
+	}
+}
+
+func (n *NTPCollector) GetName() string {
+	return net.JoinHostPort(n.address, n.port)
+}
+
+func (n *NTPCollector) SetTimeout(timeout time.Duration) {
+	n.timeout = timeout
+}
+
+// ExecuteTest sends a SNTP request and uses the round trip delay without the processing time of the server as delay
+func (n *NTPCollector) ExecuteTest() (DataPointInterface, error) {
+	conn, err := net.DialTimeout(n.network, net.JoinHostPort(n.address, n.port), n.timeout)
+	if err != nil {
+		return &DataPoint{
+			result:     false,
+			errorClass: getNetworkErrorClass(err),
+			message:    err.Error(),
+		}, nil
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(n.timeout))
+
+	// the transmit timestamp is returned as originate timestamp to match the answer with the request
+	request := make([]byte, ntpPacketSize)
+	request[0] = ntpRequest
+	sent := time.Now()
+	binary.BigEndian.PutUint64(request[40:], toNTPTime(sent))
+
+	answer := make([]byte, ntpPacketSize)
+	_, err = conn.Write(request)
+	size := 0
+	if err == nil {
+		size, err = conn.Read(answer)
+	}
+	received := time.Now()
+	if err != nil {
+		return &DataPoint{
+			delay:      received.Sub(sent),
+			result:     false,
+			errorClass: getNetworkErrorClass(err),
+			message:    err.Error(),
+		}, nil
+	}
+
+	if size < ntpPacketSize || answer[0]&0x07 != ntpModeServer ||
+		binary.BigEndian.Uint64(answer[24:]) != binary.BigEndian.Uint64(request[40:]) {
+		return &DataPoint{
+			delay:      received.Sub(sent),
+			result:     false,
+			errorClass: "invalid answer",
+			message:    "answer is no reply to the request",
+		}, nil
+	}
+
+	// delay and offset as defined by RFC 4330
+	serverReceived := fromNTPTime(binary.BigEndian.Uint64(answer[32:]))
+	serverSent := fromNTPTime(binary.BigEndian.Uint64(answer[40:]))
+	delay := received.Sub(sent) - serverSent.Sub(serverReceived)
+	offset := (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2
+
+	leap := answer[0] >> 6
+	stratum := int(answer[1])
+	metadata := map[string]string{
+		"offset":  fmt.Sprintf("%+.2f ms", float64(offset)/float64(time.Millisecond)),
+		"stratum": strconv.Itoa(stratum),
+		"leap":    ntpLeapIndicators[leap],
+		"refid":   getNTPReferenceID(stratum, answer[12:16]),
+	}
+
+	errorClass, message := "", ""
+	switch {
+	case stratum == 0:
+		errorClass = "kiss of death"
+		message = "kiss code " + metadata["refid"]
+	case leap == ntpLeapAlarm || stratum >= ntpMaxStratum:
+		errorClass = "unsynchronised"
+		message = fmt.Sprintf("server is not synchronised, stratum %d and leap indicator %s", stratum, metadata["leap"])
+	case n.maxOffset > 0 && (offset > n.maxOffset || offset < -n.maxOffset):
+		errorClass = "offset"
+		message = fmt.Sprintf("offset %s exceeds %s", metadata["offset"], n.maxOffset)
+	}
+	if errorClass != "" {
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: errorClass,
+			message:    message,
+			metadata:   metadata,
+		}, nil
+	}
+
+	// Correct result
+	return &DataPoint{
+		delay:    delay,
+		result:   true,
+		metadata: metadata,
+	}, nil
+}
+
+// toNTPTime converts the time into a NTP timestamp with seconds since 1900 and the fraction of a second
+func toNTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochDelta)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+// fromNTPTime converts a NTP timestamp into a time
+func fromNTPTime(ntpTime uint64) time.Time {
+	seconds := int64(ntpTime>>32) - ntpEpochDelta
+	nanoseconds := int64((ntpTime & 0xffffffff) * uint64(time.Second) >> 32)
+	return time.Unix(seconds, nanoseconds)
+}
+
+// getNTPReferenceID returns the reference id, a code like "GPS" for primary servers or an IPv4 address
+func getNTPReferenceID(stratum int, id []byte) string {
+	if stratum <= 1 {
+		end := len(id)
+		for end > 0 && id[end-1] == 0 {
+			end--
+		}
+		return string(id[:end])
+	}
+	return net.IP(id).String()
+}
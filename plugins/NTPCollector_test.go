@@ -0,0 +1,165 @@
+package plugins
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNTPTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		time    time.Time
+		ntpTime uint64
+	}{
+		{
+			name:    "unix epoch",
+			time:    time.Unix(0, 0),
+			ntpTime: ntpEpochDelta << 32,
+		},
+		{
+			name:    "half a second",
+			time:    time.Unix(0, int64(500*time.Millisecond)),
+			ntpTime: ntpEpochDelta<<32 | 0x80000000,
+		},
+		{
+			name:    "quarter second in 2024",
+			time:    time.Date(2024, 1, 1, 0, 0, 0, int(250*time.Millisecond), time.UTC),
+			ntpTime: 3913056000<<32 | 0x40000000,
+		},
+		{
+			name:    "last second of era 0",
+			time:    time.Date(2036, 2, 7, 6, 28, 15, 0, time.UTC),
+			ntpTime: 0xffffffff << 32,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := toNTPTime(test.time); got != test.ntpTime {
+				t.Errorf("toNTPTime = %#x, want %#x", got, test.ntpTime)
+			}
+			if got := fromNTPTime(test.ntpTime); !got.Equal(test.time) {
+				t.Errorf("fromNTPTime = %s, want %s", got, test.time)
+			}
+		})
+	}
+}
+
+func TestNTPTimeRoundTrip(t *testing.T) {
+	// the fraction has a resolution of 233 ps, converting back may truncate a nanosecond
+	for _, nanoseconds := range []int64{1, 999, 123456789, 999999999} {
+		original := time.Unix(1700000000, nanoseconds)
+		got := fromNTPTime(toNTPTime(original))
+		if diff := original.Sub(got); diff < 0 || diff > time.Nanosecond {
+			t.Errorf("round trip of %s = %s, difference %s", original, got, diff)
+		}
+	}
+}
+
+func TestGetNTPReferenceID(t *testing.T) {
+	tests := []struct {
+		stratum int
+		id      []byte
+		want    string
+	}{
+		{stratum: 0, id: []byte("RATE"), want: "RATE"},
+		{stratum: 1, id: []byte{'G', 'P', 'S', 0}, want: "GPS"},
+		{stratum: 1, id: []byte{0, 0, 0, 0}, want: ""},
+		{stratum: 2, id: []byte{192, 0, 2, 1}, want: "192.0.2.1"},
+	}
+	for _, test := range tests {
+		if got := getNTPReferenceID(test.stratum, test.id); got != test.want {
+			t.Errorf("getNTPReferenceID(%d, %v) = %q, want %q", test.stratum, test.id, got, test.want)
+		}
+	}
+}
+
+// startNTPServer starts a fake NTP server which answers every request with the leap indicator, stratum and a
+// clock that is off by the offset, the processing takes the given time
+func startNTPServer(t *testing.T, leap byte, stratum byte, offset time.Duration, processing time.Duration) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		request := make([]byte, ntpPacketSize)
+		for {
+			_, address, err := conn.ReadFrom(request)
+			if err != nil {
+				return
+			}
+			received := time.Now().Add(offset)
+			answer := make([]byte, ntpPacketSize)
+			answer[0] = leap<<6 | ntpVersion<<3 | ntpModeServer
+			answer[1] = stratum
+			copy(answer[12:16], "GPS")
+			copy(answer[24:32], request[40:48])
+			binary.BigEndian.PutUint64(answer[32:], toNTPTime(received))
+			binary.BigEndian.PutUint64(answer[40:], toNTPTime(received.Add(processing)))
+			time.Sleep(processing)
+			_, _ = conn.WriteTo(answer, address)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestNTPCollector(t *testing.T) {
+	tests := []struct {
+		name       string
+		leap       byte
+		stratum    byte
+		offset     time.Duration
+		maxOffset  string
+		errorClass string
+	}{
+		{name: "synchronised", stratum: 1},
+		{name: "kiss of death", stratum: 0, errorClass: "kiss of death"},
+		{name: "unsynchronised leap indicator", leap: ntpLeapAlarm, stratum: 2, errorClass: "unsynchronised"},
+		{name: "unsynchronised stratum", stratum: ntpMaxStratum, errorClass: "unsynchronised"},
+		{name: "offset within the limit", stratum: 1, offset: time.Second, maxOffset: "10s"},
+		{name: "offset above the limit", stratum: 1, offset: -time.Minute, maxOffset: "10s", errorClass: "offset"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			processing := 50 * time.Millisecond
+			host, port, err := net.SplitHostPort(startNTPServer(t, test.leap, test.stratum, test.offset, processing))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			n := &NTPCollector{}
+			err = n.SetConfig(map[string]string{
+				"IPAddress": host,
+				"Port":      port,
+				"MaxOffset": test.maxOffset,
+				"Timeout":   "2s",
+			})
+			if err != nil {
+				t.Fatalf("SetConfig: %v", err)
+			}
+
+			dataPoint, err := n.ExecuteTest()
+			if err != nil {
+				t.Fatalf("ExecuteTest: %v", err)
+			}
+			if dataPoint.GetErrorClass() != test.errorClass {
+				t.Fatalf("error class = %q, want %q (%s)", dataPoint.GetErrorClass(), test.errorClass,
+					dataPoint.GetMessage())
+			}
+			if dataPoint.GetResult() != (test.errorClass == "") {
+				t.Errorf("result = %v with error class %q", dataPoint.GetResult(), test.errorClass)
+			}
+			// the processing time of the server is not part of the delay
+			if delay := dataPoint.GetDelay(); delay >= processing {
+				t.Errorf("delay = %s includes the processing time %s", delay, processing)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	pmtuMinimumIPv4 = 68   // smallest MTU of IPv4, smaller probes are not sent
+	pmtuMinimumIPv6 = 1280 // smallest MTU of IPv6, smaller probes are not sent
+	pmtuUDPPort     = 33434
+
+	pmtuProbeTimeoutShare = 4                     // a lost probe waits at most this share of the test timeout
+	pmtuRoundTripFactor   = 3                     // after the first reply a lost probe waits this many round trips
+	pmtuMinProbeTimeout   = 10 * time.Millisecond // shortest time a probe waits for its reply
+)
+
+// PMTUCollector represents a single target whose path MTU should be discovered with DF-set probes of varying size
+type PMTUCollector struct {
+	timeout         time.Duration
+	address         string // IP or FQDN of the target
+	networkProtocol string // 'ip', 'ip4' or 'ip6'
+	protocol        string // 'icmp' or 'udp'
+	port            int    // destination port of the UDP probes
+	maxMTU          int    // size of the first probe
+	expectedMTU     int    // a smaller path MTU fails the test, 0 to accept every path MTU
+	lastMTU         int    // path MTU of the previous test to detect changes, 0 if unknown
+}
+
+func (p *PMTUCollector) New() PluginInterface {
+	return &PMTUCollector{}
+}
+
+// SetConfig is used to set a config for this TestPlugin
+// The config is a map of key/value pairs
+func (p *PMTUCollector) SetConfig(config map[string]string) error {
+	// Parse IPAddress
+	if _, ok := config["IPAddress"]; !ok {
+		return errors.New("missing IPAddress")
+	}
+	p.address = config["IPAddress"]
+	p.networkProtocol = getNetwork("ip", config)
+
+	// Parse the probe protocol
+	switch config["Protocol"] {
+	case "", "icmp":
+		p.protocol = "icmp"
+	case "udp":
+		p.protocol = "udp"
+	default:
+		return errors.New("invalid Protocol")
+	}
+	p.port = pmtuUDPPort
+	if v, ok := config["Port"]; ok && v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port < 1 || port > 65535 {
+			return errors.New("invalid Port")
+		}
+		p.port = port
+	}
+
+	// Parse the MTUs
+	p.maxMTU = 1500
+	if v, ok := config["MaxMTU"]; ok && v != "" {
+		mtu, err := strconv.Atoi(v)
+		if err != nil || mtu < pmtuMinimumIPv4 || mtu > 65535 {
+			return errors.New("invalid MaxMTU")
+		}
+		p.maxMTU = mtu
+	}
+	p.expectedMTU = 0
+	if v, ok := config["ExpectedMTU"]; ok && v != "" {
+		mtu, err := strconv.Atoi(v)
+		if err != nil || mtu < 0 || mtu > p.maxMTU {
+			return errors.New("invalid ExpectedMTU")
+		}
+		p.expectedMTU = mtu
+	}
+	// the old path MTU may be outside of the new range
+	p.lastMTU = 0
+
+	// Parse Timeout
+	if _, ok := config["Timeout"]; !ok {
+		return errors.New("missing Timeout")
+	}
+	timeout, err := time.ParseDuration(config["Timeout"])
+	if err != nil {
+		return errors.New("invalid Timeout")
+	}
+	p.timeout = timeout
+
+	return nil
+}
+
+// GetOptions returns the config options of the path MTU check
+func (p *PMTUCollector) GetOptions() []Option {
+	return []Option{
+		{
+			Name:        "Protocol",
+			Flag:        "protocol",
+			Type:        OptionTypeEnum,
+			Values:      []string{"icmp", "udp"},
+			Default:     "icmp",
+			Description: "send echo requests or UDP datagrams which the target answers with port unreachable",
+		},
+		{
+			Name:        "Port",
+			Flag:        "port",
+			Type:        OptionTypeInt,
+			Default:     strconv.Itoa(pmtuUDPPort),
+			Description: "closed UDP `port` of the target for the udp protocol",
+		},
+		{
+			Name:        "MaxMTU",
+			Flag:        "max",
+			Type:        OptionTypeInt,
+			Default:     "1500",
+			Description: "largest probed `MTU`, usually the MTU of the local interface",
+		},
+		{
+			Name:        "ExpectedMTU",
+			Flag:        "expect",
+			Type:        OptionTypeInt,
+			Description: "fail the test if the path MTU is below this `MTU`",
+		},
+	}
+}
+
+// GetColumns returns the discovered path MTU as additional column
+func (p *PMTUCollector) GetColumns() []Column {
+	return []Column{
+		{Header: "PMTU", Key: "mtu", Width: len("65535")},
+	}
+}
+
+func (p *PMTUCollector) GetName() string {
+	if p.protocol == "udp" {
+		return fmt.Sprintf("%s (%s, udp/%d)", p.address, p.networkProtocol, p.port)
+	}
+	return fmt.Sprintf("%s (%s)", p.address, p.networkProtocol)
+}
+
+func (p *PMTUCollector) SetTimeout(timeout time.Duration) {
+	p.timeout = timeout
+}
+
+// ExecuteTest sends a probe with the maximum MTU and searches the path MTU if it does not reach the target. Routers
+// report their smaller MTU which is probed next. Silently dropped probes (MTU blackholes) wait for a quarter of the
+// timeout until the first reply and afterwards for a few round trips, the whole test takes at most the timeout.
+func (p *PMTUCollector) ExecuteTest() (DataPointInterface, error) {
+	address, err := net.ResolveIPAddr(p.networkProtocol, p.address)
+	if err != nil {
+		return &DataPoint{
+			result:     false,
+			errorClass: ErrorClassNetwork,
+			message:    err.Error(),
+		}, nil
+	}
+	probe := icmpProbe{address: address, dontFragment: true}
+	if p.protocol == "udp" {
+		probe.udpPort = p.port
+	}
+	minimum := pmtuMinimumIPv6
+	if probe.isIPv4() {
+		minimum = pmtuMinimumIPv4
+	}
+
+	low := minimum - 1 // largest size that reached the target
+	high := p.maxMTU   // largest size that may reach the target
+	probes := 0
+	delay := time.Duration(0)
+	// the whole search has to finish within the timeout, a lost probe only waits for a part of it
+	deadline := time.Now().Add(p.timeout)
+	probeTimeout := p.timeout / pmtuProbeTimeoutShare
+	slowest := time.Duration(0) // slowest round trip of the probes that reached the target
+	for probe.size = high; low < high; {
+		probe.timeout = min(probeTimeout, time.Until(deadline))
+		if probe.timeout <= 0 {
+			message := fmt.Sprintf("no probe of %d to %d bytes reached the target within %s", minimum, high, p.timeout)
+			if low >= minimum {
+				message = fmt.Sprintf("the path MTU search did not finish within %s, it is between %d and %d", p.timeout,
+					low, high)
+			}
+			return &DataPoint{
+				delay:      delay,
+				result:     false,
+				errorClass: ErrorClassTimeout,
+				message:    message,
+			}, nil
+		}
+
+		reply, err := probe.send()
+		probes++
+		if errors.Is(err, errICMPPermission) {
+			return &DataPoint{
+				result:     false,
+				errorClass: ErrorClassPlugin,
+				message:    err.Error(),
+			}, nil
+		}
+		if err != nil && getNetworkErrorClass(err) != ErrorClassTimeout {
+			return &DataPoint{
+				delay:      delay,
+				result:     false,
+				errorClass: ErrorClassNetwork,
+				message:    err.Error(),
+			}, nil
+		}
+
+		switch {
+		case err != nil:
+			// lost or dropped without an ICMP error
+			high = probe.size - 1
+		case reply.kind == icmpReplyEcho || reply.kind == icmpReplyPortUnreachable:
+			low = probe.size
+			delay = reply.delay
+			// wait for the next probes relative to the round trip time of the target
+			slowest = max(slowest, reply.delay)
+			probeTimeout = min(p.timeout/pmtuProbeTimeoutShare, max(slowest*pmtuRoundTripFactor, pmtuMinProbeTimeout))
+		case reply.kind == icmpReplyTooBig:
+			high = probe.size - 1
+			if reply.mtu > low && reply.mtu < probe.size {
+				// probe the reported MTU next
+				high = reply.mtu
+				probe.size = high
+				continue
+			}
+		default:
+			return &DataPoint{
+				delay:      reply.delay,
+				result:     false,
+				errorClass: "unreachable",
+				message:    fmt.Sprintf("%s from %s", reply.kind, reply.from),
+			}, nil
+		}
+		probe.size = (low + high + 1) / 2
+	}
+
+	if low < minimum {
+		return &DataPoint{
+			result:     false,
+			errorClass: ErrorClassTimeout,
+			message:    fmt.Sprintf("no probe of %d to %d bytes reached the target", minimum, p.maxMTU),
+		}, nil
+	}
+
+	metadata := map[string]string{
+		"mtu":    strconv.Itoa(low),
+		"probes": strconv.Itoa(probes),
+	}
+	lastMTU := p.lastMTU
+	p.lastMTU = low
+	if lastMTU != 0 && lastMTU != low {
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: "mtu changed",
+			message:    fmt.Sprintf("path MTU changed from %d to %d", lastMTU, low),
+			metadata:   metadata,
+		}, nil
+	}
+	if low < p.expectedMTU {
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: "mtu",
+			message:    fmt.Sprintf("path MTU %d is below %d", low, p.expectedMTU),
+			metadata:   metadata,
+		}, nil
+	}
+
+	// Correct result
+	return &DataPoint{
+		delay:    delay,
+		result:   true,
+		metadata: metadata,
+	}, nil
+}
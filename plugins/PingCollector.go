@@ -3,7 +3,9 @@ package plugins
 import (
 	"errors"
 	"fmt"
+	"net"
 	"runtime"
+	"strconv"
 	"time"
 
 	"github.com/go-ping/ping"
@@ -14,6 +16,8 @@ type PingCollector struct {
 	timeout         time.Duration
 	address         string // Address of the DNS Resolver, IP or FQDN
 	networkProtocol string // 'ip', 'ip4' or 'ip6'
+	ttl             int    // TTL of the probes to test a single hop on the path, 0 to test the address
+	ipv6            bool   // the address is resolved to IPv6, so are the hops on its path
 }
 
 func (p *PingCollector) New() PluginInterface {
@@ -46,27 +50,72 @@ func (p *PingCollector) SetConfig(config map[string]string) error {
 		p.networkProtocol = "ip6"
 	}
 
+	// Resolve the address once, a name that can not be resolved is rejected instead of failing every test
+	resolved, err := net.ResolveIPAddr(p.networkProtocol, p.address)
+	if err != nil {
+		return fmt.Errorf("invalid IPAddress: %w", err)
+	}
+	p.ipv6 = resolved.IP.To4() == nil
+
+	// Parse TTL
+	p.ttl = 0
+	if v, ok := config["TTL"]; ok && v != "" {
+		ttl, err := strconv.Atoi(v)
+		if err != nil || ttl < 0 || ttl > 255 {
+			return errors.New("invalid TTL")
+		}
+		p.ttl = ttl
+	}
+
 	return nil
 }
 
 // GetName returns the name of the collector
 func (p *PingCollector) GetName() string {
+	if p.ttl > 0 {
+		return fmt.Sprintf("%s (%s) hop %2d", p.address, p.networkProtocol, p.ttl)
+	}
 	return fmt.Sprintf("%s (%s)", p.address, p.networkProtocol)
 }
 
+// GetOptions returns the config options of the ping check
+func (p *PingCollector) GetOptions() []Option {
+	return []Option{
+		{
+			Name:        "TTL",
+			Flag:        "ttl",
+			Type:        OptionTypeInt,
+			Description: "test the hop at this `TTL` on the path to the address instead of the address, needs raw sockets",
+		},
+	}
+}
+
+// GetColumns returns the address of the tested hop as additional column if a TTL is set
+func (p *PingCollector) GetColumns() []Column {
+	if p.ttl == 0 {
+		return nil
+	}
+	width := len("255.255.255.255")
+	if p.ipv6 {
+		width = len("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+	}
+	return []Column{
+		{Header: "Hop", Key: "hop", Width: width},
+	}
+}
+
 // SetTimeout sets the timeout for the pinger
 func (p *PingCollector) SetTimeout(timeout time.Duration) {
 	p.timeout = timeout
 }
 
-// getNewPinger returns a new pinger instance
-func (p *PingCollector) getNewPinger() *ping.Pinger {
+// getNewPinger returns a new pinger instance, the address is resolved again for every test
+func (p *PingCollector) getNewPinger() (*ping.Pinger, error) {
 	// create pinger manually to be able to set IPv4 or IPv6
 	pinger := ping.New(p.address)
 	pinger.SetNetwork(p.networkProtocol)
-	err := pinger.Resolve()
-	if err != nil {
-		panic(err)
+	if err := pinger.Resolve(); err != nil {
+		return nil, err
 	}
 
 	pinger.Count = 1
@@ -77,11 +126,22 @@ func (p *PingCollector) getNewPinger() *ping.Pinger {
 		pinger.SetPrivileged(true)
 	}
 
-	return pinger
+	return pinger, nil
 }
 
 func (p *PingCollector) ExecuteTest() (DataPointInterface, error) {
-	pinger := p.getNewPinger()
+	if p.ttl > 0 {
+		return p.executeHopTest()
+	}
+
+	pinger, err := p.getNewPinger()
+	if err != nil {
+		return &DataPoint{
+			result:     false,
+			errorClass: ErrorClassNetwork,
+			message:    err.Error(),
+		}, nil
+	}
 	pinger.Run() // Blocks until finished.
 
 	stats := pinger.Statistics()
@@ -89,8 +149,10 @@ func (p *PingCollector) ExecuteTest() (DataPointInterface, error) {
 	// no answer
 	if stats.PacketsRecv == 0 {
 		return &DataPoint{
-			delay:  0,
-			result: false,
+			delay:      0,
+			result:     false,
+			errorClass: ErrorClassTimeout,
+			message:    "no echo reply received",
 		}, nil
 	} else {
 		return &DataPoint{
@@ -99,3 +161,59 @@ func (p *PingCollector) ExecuteTest() (DataPointInterface, error) {
 		}, nil
 	}
 }
+
+// executeHopTest sends a probe with the TTL, the router at this hop answers with time exceeded and the address itself
+// with an echo reply if it is reached within the TTL
+func (p *PingCollector) executeHopTest() (DataPointInterface, error) {
+	address, err := net.ResolveIPAddr(p.networkProtocol, p.address)
+	if err != nil {
+		return &DataPoint{
+			result:     false,
+			errorClass: ErrorClassNetwork,
+			message:    err.Error(),
+		}, nil
+	}
+
+	reply, err := icmpProbe{address: address, ttl: p.ttl, timeout: p.timeout}.send()
+	if errors.Is(err, errICMPPermission) {
+		return &DataPoint{
+			result:     false,
+			errorClass: ErrorClassPlugin,
+			message:    err.Error(),
+		}, nil
+	}
+	if err != nil {
+		errorClass := getNetworkErrorClass(err)
+		message := err.Error()
+		if errorClass == ErrorClassTimeout {
+			message = fmt.Sprintf("no reply from hop %d", p.ttl)
+		}
+		return &DataPoint{
+			delay:      reply.delay,
+			result:     false,
+			errorClass: errorClass,
+			message:    message,
+		}, nil
+	}
+
+	metadata := map[string]string{
+		"hop":     reply.from.String(),
+		"reached": strconv.FormatBool(reply.kind == icmpReplyEcho),
+	}
+	if reply.kind == icmpReplyUnreachable {
+		return &DataPoint{
+			delay:      reply.delay,
+			result:     false,
+			errorClass: "unreachable",
+			message:    "destination unreachable from " + reply.from.String(),
+			metadata:   metadata,
+		}, nil
+	}
+
+	// Correct result
+	return &DataPoint{
+		delay:    reply.delay,
+		result:   true,
+		metadata: metadata,
+	}, nil
+}
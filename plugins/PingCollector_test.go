@@ -0,0 +1,52 @@
+package plugins
+
+import "testing"
+
+func TestPingCollectorSetConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]string
+		wantErr bool
+	}{
+		{name: "address", config: map[string]string{"IPAddress": "127.0.0.1", "Timeout": "1s"}},
+		{name: "hop", config: map[string]string{"IPAddress": "127.0.0.1", "Timeout": "1s", "TTL": "3"}},
+		// an unknown name is rejected instead of failing on every test
+		{name: "unresolvable", config: map[string]string{"IPAddress": "unknown.invalid", "Timeout": "1s"}, wantErr: true},
+		{name: "family", config: map[string]string{"IPAddress": "127.0.0.1", "Timeout": "1s", "IPv6": "true"}, wantErr: true},
+		{name: "TTL", config: map[string]string{"IPAddress": "127.0.0.1", "Timeout": "1s", "TTL": "256"}, wantErr: true},
+		{name: "timeout", config: map[string]string{"IPAddress": "127.0.0.1"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		p := &PingCollector{}
+		if err := p.SetConfig(test.config); (err != nil) != test.wantErr {
+			t.Errorf("%s: SetConfig() = %v, want an error: %v", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestPingCollectorGetColumns(t *testing.T) {
+	tests := []struct {
+		config map[string]string
+		width  int
+	}{
+		{config: map[string]string{"IPAddress": "127.0.0.1", "Timeout": "1s"}},
+		{config: map[string]string{"IPAddress": "127.0.0.1", "Timeout": "1s", "TTL": "3"}, width: 15},
+		// the hops of an IPv6 address are not truncated
+		{config: map[string]string{"IPAddress": "::1", "Timeout": "1s", "TTL": "3"}, width: 39},
+	}
+
+	for _, test := range tests {
+		p := &PingCollector{}
+		if err := p.SetConfig(test.config); err != nil {
+			t.Fatalf("SetConfig(%v): %v", test.config, err)
+		}
+		width := 0
+		if columns := p.GetColumns(); len(columns) > 0 {
+			width = columns[0].Width
+		}
+		if width != test.width {
+			t.Errorf("SetConfig(%v): width of the hop column = %d, want %d", test.config, width, test.width)
+		}
+	}
+}
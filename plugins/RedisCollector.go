@@ -0,0 +1,178 @@
+package plugins
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// redisPasswordEnv is the environment variable with the password if it is not configured, the same as of redis-cli
+const redisPasswordEnv = "REDISCLI_AUTH"
+
+// RedisCollector represents a single Redis server that should be checked with PING
+type RedisCollector struct {
+	timeout  time.Duration
+	address  string // IP or FQDN of the server
+	port     string // Port of the Redis service (default 6379)
+	network  string // 'tcp', 'tcp4' or 'tcp6'
+	user     string // ACL user of AUTH, empty for the default user
+	password string // password of AUTH, empty to skip AUTH
+}
+
+func (c *RedisCollector) New() PluginInterface {
+	return &RedisCollector{}
+}
+
+// SetConfig is used to set a config for this TestPlugin
+// The config is a map of key/value pairs
+func (c *RedisCollector) SetConfig(config map[string]string) error {
+	// Parse IPAddress
+	if _, ok := config["IPAddress"]; !ok {
+		return errors.New("missing IPAddress")
+	}
+	c.address = config["IPAddress"]
+	c.network = getNetwork("tcp", config)
+
+	// Parse Port of Redis Server
+	if v, ok := config["Port"]; !ok || v == "" {
+		c.port = "6379" // default port
+	} else {
+		c.port = config["Port"]
+	}
+
+	// Parse credentials
+	c.user = config["User"]
+	c.password = getConfigOrEnv(config, "Password", redisPasswordEnv)
+
+	// Parse Timeout
+	if _, ok := config["Timeout"]; !ok {
+		return errors.New("missing Timeout")
+	}
+	timeout, err := time.ParseDuration(config["Timeout"])
+	if err != nil {
+		return errors.New("invalid Timeout")
+	}
+	c.timeout = timeout
+
+	return nil
+}
+
+// GetOptions returns the config options of the Redis check
+func (c *RedisCollector) GetOptions() []Option {
+	return []Option{
+		{
+			Name:        "Port",
+			Flag:        "port",
+			Type:        OptionTypeInt,
+			Default:     "6379",
+			Description: "`port` of the Redis service",
+		},
+		{
+			Name:        "User",
+			Flag:        "user",
+			Type:        OptionTypeString,
+			Description: "ACL `user` for AUTH, empty for the default user",
+		},
+		{
+			Name:        "Password",
+			Flag:        "password",
+			Type:        OptionTypeString,
+			Description: "password for AUTH from the config file or " + redisPasswordEnv + ", it has no command line flag",
+			Secret:      true,
+		},
+	}
+}
+
+func (c *RedisCollector) GetName() string {
+	return net.JoinHostPort(c.address, c.port)
+}
+
+func (c *RedisCollector) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// ExecuteTest connects to the server, authenticates if a password is set and sends PING
+func (c *RedisCollector) ExecuteTest() (DataPointInterface, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout(c.network, net.JoinHostPort(c.address, c.port), c.timeout)
+	if err != nil {
+		return &DataPoint{
+			delay:      time.Since(start),
+			result:     false,
+			errorClass: getNetworkErrorClass(err),
+			message:    err.Error(),
+		}, nil
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(start.Add(c.timeout))
+	r := bufio.NewReader(conn)
+
+	if c.password != "" {
+		command := []string{"AUTH", c.password}
+		if c.user != "" {
+			command = []string{"AUTH", c.user, c.password}
+		}
+		if reply, err := sendRedisCommand(conn, r, command...); err != nil {
+			return getRedisErrorDataPoint(time.Since(start), err), nil
+		} else if reply != "+OK" {
+			return &DataPoint{
+				delay:      time.Since(start),
+				result:     false,
+				errorClass: "auth",
+				message:    strings.TrimPrefix(reply, "-"),
+			}, nil
+		}
+	}
+
+	reply, err := sendRedisCommand(conn, r, "PING")
+	delay := time.Since(start)
+	if err != nil {
+		return getRedisErrorDataPoint(delay, err), nil
+	}
+	if reply != "+PONG" {
+		// error replies start with their kind, e.g. "-NOAUTH Authentication required" or "-LOADING ..."
+		errorClass := "unexpected reply"
+		if strings.HasPrefix(reply, "-") {
+			errorClass = strings.ToLower(strings.Fields(reply[1:] + " error")[0])
+		}
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: errorClass,
+			message:    strings.TrimPrefix(reply, "-"),
+		}, nil
+	}
+
+	// Correct result
+	return &DataPoint{
+		delay:  delay,
+		result: true,
+	}, nil
+}
+
+// sendRedisCommand sends the command as RESP array and returns the first line of the reply
+func sendRedisCommand(conn net.Conn, r *bufio.Reader, args ...string) (string, error) {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("*%d\r\n", len(args)))
+	for _, arg := range args {
+		b.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg))
+	}
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		return "", err
+	}
+	return readLine(r)
+}
+
+// getRedisErrorDataPoint returns a failed DataPoint for a network error
+func getRedisErrorDataPoint(delay time.Duration, err error) *DataPoint {
+	return &DataPoint{
+		delay:      delay,
+		result:     false,
+		errorClass: getNetworkErrorClass(err),
+		message:    err.Error(),
+	}
+}
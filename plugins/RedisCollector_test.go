@@ -0,0 +1,209 @@
+package plugins
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// readRESPCommand reads a command which is sent as RESP array of bulk strings
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("no array %q", line)
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		if line, err = readLine(r); err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("no bulk string %q", line)
+		}
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, length+2)
+		if _, err = io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		if string(arg[length:]) != "\r\n" {
+			return nil, fmt.Errorf("bulk string %q has the wrong length", arg)
+		}
+		args[i] = string(arg[:length])
+	}
+	return args, nil
+}
+
+// startRedisServer starts a fake Redis server which answers the commands, the key is the command with its arguments
+// separated by spaces, unknown commands close the connection
+func startRedisServer(t *testing.T, replies map[string]string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			args, err := readRESPCommand(r)
+			if err != nil {
+				return
+			}
+			reply, ok := replies[strings.Join(args, " ")]
+			if !ok {
+				return
+			}
+			if _, err = io.WriteString(conn, reply); err != nil {
+				return
+			}
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestRedisCollector(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       string
+		password   string
+		replies    map[string]string
+		errorClass string
+		message    string
+	}{
+		{
+			name:    "pong",
+			replies: map[string]string{"PING": "+PONG\r\n"},
+		},
+		{
+			name:     "password",
+			password: "secret value",
+			replies:  map[string]string{"AUTH secret value": "+OK\r\n", "PING": "+PONG\r\n"},
+		},
+		{
+			name:     "ACL user",
+			user:     "monitor",
+			password: "secret",
+			replies:  map[string]string{"AUTH monitor secret": "+OK\r\n", "PING": "+PONG\r\n"},
+		},
+		{
+			name:       "wrong password",
+			password:   "wrong",
+			replies:    map[string]string{"AUTH wrong": "-WRONGPASS invalid username-password pair\r\n"},
+			errorClass: "auth",
+			message:    "WRONGPASS invalid username-password pair",
+		},
+		{
+			name:       "missing password",
+			replies:    map[string]string{"PING": "-NOAUTH Authentication required.\r\n"},
+			errorClass: "noauth",
+			message:    "NOAUTH Authentication required.",
+		},
+		{
+			name:       "loading",
+			replies:    map[string]string{"PING": "-LOADING Redis is loading the dataset in memory\r\n"},
+			errorClass: "loading",
+			message:    "LOADING Redis is loading the dataset in memory",
+		},
+		{
+			name:       "error without kind",
+			replies:    map[string]string{"PING": "-\r\n"},
+			errorClass: "error",
+		},
+		{
+			name:       "bulk string",
+			replies:    map[string]string{"PING": "$4\r\nPONG\r\n"},
+			errorClass: "unexpected reply",
+			message:    "$4",
+		},
+		{
+			name:       "closed connection",
+			replies:    map[string]string{},
+			errorClass: ErrorClassNetwork,
+			message:    "EOF",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host, port, err := net.SplitHostPort(startRedisServer(t, test.replies))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv(redisPasswordEnv, "")
+
+			c := &RedisCollector{}
+			err = c.SetConfig(map[string]string{
+				"IPAddress": host,
+				"Port":      port,
+				"User":      test.user,
+				"Password":  test.password,
+				"Timeout":   "2s",
+			})
+			if err != nil {
+				t.Fatalf("SetConfig: %v", err)
+			}
+
+			dataPoint, err := c.ExecuteTest()
+			if err != nil {
+				t.Fatalf("ExecuteTest: %v", err)
+			}
+			if dataPoint.GetErrorClass() != test.errorClass {
+				t.Fatalf("error class = %q, want %q (%s)", dataPoint.GetErrorClass(), test.errorClass,
+					dataPoint.GetMessage())
+			}
+			if dataPoint.GetResult() != (test.errorClass == "") {
+				t.Errorf("result = %v with error class %q", dataPoint.GetResult(), test.errorClass)
+			}
+			if dataPoint.GetMessage() != test.message {
+				t.Errorf("message = %q, want %q", dataPoint.GetMessage(), test.message)
+			}
+		})
+	}
+}
+
+func TestRedisPasswordFromEnv(t *testing.T) {
+	t.Setenv(redisPasswordEnv, "from env")
+
+	tests := []struct {
+		name     string
+		config   map[string]string
+		password string
+	}{
+		{name: "environment", config: map[string]string{}, password: "from env"},
+		{name: "config", config: map[string]string{"Password": "from config"}, password: "from config"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.config["IPAddress"] = "127.0.0.1"
+			test.config["Timeout"] = "1s"
+			c := &RedisCollector{}
+			if err := c.SetConfig(test.config); err != nil {
+				t.Fatalf("SetConfig: %v", err)
+			}
+			if c.password != test.password {
+				t.Errorf("password = %q, want %q", c.password, test.password)
+			}
+		})
+	}
+}
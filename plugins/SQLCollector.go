@@ -0,0 +1,285 @@
+package plugins
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// Database drivers of the SQLCollector
+const (
+	SQLDriverPostgres = "postgres"
+	SQLDriverMySQL    = "mysql"
+)
+
+// sqlDriver contains the defaults of a database driver, the environment variables are the same as of its client
+type sqlDriver struct {
+	port        string // default port
+	userEnv     string // environment variable with the user if it is not configured
+	passwordEnv string // environment variable with the password if it is not configured
+}
+
+var sqlDrivers = map[string]sqlDriver{
+	SQLDriverPostgres: {port: "5432", userEnv: "PGUSER", passwordEnv: "PGPASSWORD"},
+	SQLDriverMySQL:    {port: "3306", userEnv: "MYSQL_USER", passwordEnv: "MYSQL_PWD"},
+}
+
+// SQLCollector represents a single PostgreSQL or MySQL server that should be checked with a new connection and SELECT 1
+type SQLCollector struct {
+	driver   string // SQLDriverPostgres or SQLDriverMySQL
+	timeout  time.Duration
+	address  string // IP or FQDN of the server
+	port     string // Port of the database service
+	network  string // 'tcp', 'tcp4' or 'tcp6'
+	user     string
+	password string
+	database string // database to connect to, empty for the default of the server
+	tls      string // off, skip-verify or verify
+
+	mutex sync.Mutex // protects the database between a new config and a test
+	db    *sql.DB    // opens a new connection for every test, idle connections are not kept
+}
+
+// NewSQLCollector creates a new SQLCollector for the driver, SQLDriverPostgres or SQLDriverMySQL
+func NewSQLCollector(driver string) *SQLCollector {
+	return &SQLCollector{driver: driver}
+}
+
+func (c *SQLCollector) New() PluginInterface {
+	return &SQLCollector{driver: c.driver}
+}
+
+// SetConfig is used to set a config for this TestPlugin
+// The config is a map of key/value pairs
+func (c *SQLCollector) SetConfig(config map[string]string) error {
+	defaults, ok := sqlDrivers[c.driver]
+	if !ok {
+		return errors.New("unknown driver " + c.driver)
+	}
+
+	// Parse IPAddress
+	if _, ok := config["IPAddress"]; !ok {
+		return errors.New("missing IPAddress")
+	}
+	c.address = config["IPAddress"]
+	c.network = getNetwork("tcp", config)
+
+	// Parse Port of the database server
+	if v, ok := config["Port"]; !ok || v == "" {
+		c.port = defaults.port
+	} else {
+		c.port = config["Port"]
+	}
+
+	// Parse credentials and database
+	c.user = getConfigOrEnv(config, "User", defaults.userEnv)
+	c.password = getConfigOrEnv(config, "Password", defaults.passwordEnv)
+	c.database = config["Database"]
+	switch config["TLS"] {
+	case "":
+		c.tls = "off"
+	case "off", "skip-verify", "verify":
+		c.tls = config["TLS"]
+	default:
+		return errors.New("invalid TLS")
+	}
+
+	// Parse Timeout
+	if _, ok := config["Timeout"]; !ok {
+		return errors.New("missing Timeout")
+	}
+	timeout, err := time.ParseDuration(config["Timeout"])
+	if err != nil {
+		return errors.New("invalid Timeout")
+	}
+	c.timeout = timeout
+
+	connector, err := c.getConnector()
+	if err != nil {
+		return err
+	}
+	db := sql.OpenDB(connector)
+	// every test measures a new connection
+	db.SetMaxIdleConns(0)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.db != nil {
+		_ = c.db.Close()
+	}
+	c.db = db
+
+	return nil
+}
+
+// getConnector returns the connector of the driver for the config
+func (c *SQLCollector) getConnector() (driver.Connector, error) {
+	if c.driver == SQLDriverMySQL {
+		config := mysql.NewConfig()
+		config.Net = c.network
+		config.Addr = net.JoinHostPort(c.address, c.port)
+		config.User = c.user
+		config.Passwd = c.password
+		config.DBName = c.database
+		config.Timeout = c.timeout
+		config.TLSConfig = map[string]string{"off": "false", "skip-verify": "skip-verify", "verify": "true"}[c.tls]
+		return mysql.NewConnector(config)
+	}
+
+	options := map[string]string{
+		"host":    c.address,
+		"port":    c.port,
+		"sslmode": map[string]string{"off": "disable", "skip-verify": "require", "verify": "verify-full"}[c.tls],
+	}
+	if c.user != "" {
+		options["user"] = c.user
+	}
+	if c.password != "" {
+		options["password"] = c.password
+	}
+	if c.database != "" {
+		options["dbname"] = c.database
+	}
+	values := make([]string, 0, len(options))
+	for key, value := range options {
+		value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+		values = append(values, key+"='"+value+"'")
+	}
+	return pq.NewConnector(strings.Join(values, " "))
+}
+
+// GetOptions returns the config options of the database check
+func (c *SQLCollector) GetOptions() []Option {
+	defaults := sqlDrivers[c.driver]
+	return []Option{
+		{
+			Name:        "Port",
+			Flag:        "port",
+			Type:        OptionTypeInt,
+			Default:     defaults.port,
+			Description: "`port` of the database service",
+		},
+		{
+			Name:        "User",
+			Flag:        "user",
+			Type:        OptionTypeString,
+			Description: "`user` to log in, " + defaults.userEnv + " is used if empty",
+		},
+		{
+			Name:        "Password",
+			Flag:        "password",
+			Type:        OptionTypeString,
+			Description: "password to log in from the config file or " + defaults.passwordEnv + ", it has no command line flag",
+			Secret:      true,
+		},
+		{
+			Name:        "Database",
+			Flag:        "database",
+			Type:        OptionTypeString,
+			Description: "`database` to connect to, empty for the default one",
+		},
+		{
+			Name:        "TLS",
+			Flag:        "tls",
+			Type:        OptionTypeEnum,
+			Values:      []string{"off", "skip-verify", "verify"},
+			Default:     "off",
+			Description: "use TLS with or without the verification of the certificate",
+		},
+	}
+}
+
+func (c *SQLCollector) GetName() string {
+	return net.JoinHostPort(c.address, c.port)
+}
+
+func (c *SQLCollector) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// ExecuteTest opens a new connection and executes SELECT 1
+func (c *SQLCollector) ExecuteTest() (DataPointInterface, error) {
+	c.mutex.Lock()
+	db := c.db
+	c.mutex.Unlock()
+	if db == nil {
+		return nil, errors.New("missing config")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := db.Conn(ctx)
+	if err == nil {
+		defer conn.Close()
+		result := 0
+		err = conn.QueryRowContext(ctx, "SELECT 1").Scan(&result)
+	}
+	delay := time.Since(start)
+	if err != nil {
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: getSQLErrorClass(err),
+			message:    err.Error(),
+		}, nil
+	}
+
+	// Correct result
+	return &DataPoint{
+		delay:  delay,
+		result: true,
+	}, nil
+}
+
+// Close closes the database
+func (c *SQLCollector) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.db == nil {
+		return nil
+	}
+	err := c.db.Close()
+	c.db = nil
+	return err
+}
+
+// getSQLErrorClass returns the error class of a failed connection or query
+func getSQLErrorClass(err error) string {
+	var pqErr *pq.Error
+	var mysqlErr *mysql.MySQLError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.As(err, &netErr):
+		return getNetworkErrorClass(err)
+	case errors.As(err, &pqErr):
+		// class 28 is invalid_authorization_specification
+		if pqErr.Code.Class() == "28" {
+			return "auth"
+		}
+		if pqErr.Code == "3D000" {
+			return "unknown database"
+		}
+		return "query"
+	case errors.As(err, &mysqlErr):
+		switch mysqlErr.Number {
+		case 1045:
+			return "auth"
+		case 1049:
+			return "unknown database"
+		}
+		return "query"
+	}
+	return "connect"
+}
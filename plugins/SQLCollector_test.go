@@ -0,0 +1,41 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+func TestGetSQLErrorClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "deadline", err: fmt.Errorf("ping: %w", context.DeadlineExceeded), want: ErrorClassTimeout},
+		{
+			name: "network",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			want: ErrorClassNetwork,
+		},
+		{name: "postgres password", err: &pq.Error{Code: "28P01"}, want: "auth"},
+		{name: "postgres authorization", err: &pq.Error{Code: "28000"}, want: "auth"},
+		{name: "postgres database", err: &pq.Error{Code: "3D000"}, want: "unknown database"},
+		{name: "postgres syntax", err: &pq.Error{Code: "42601"}, want: "query"},
+		{name: "mysql access denied", err: &mysql.MySQLError{Number: 1045}, want: "auth"},
+		{name: "mysql database", err: &mysql.MySQLError{Number: 1049}, want: "unknown database"},
+		{name: "mysql syntax", err: &mysql.MySQLError{Number: 1064}, want: "query"},
+		{name: "driver", err: errors.New("bad connection"), want: "connect"},
+	}
+
+	for _, test := range tests {
+		if got := getSQLErrorClass(test.err); got != test.want {
+			t.Errorf("%s: getSQLErrorClass(%v) = %q, want %q", test.name, test.err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tlsDefaultPorts are the ports which are used without a Port option, by STARTTLS protocol
+var tlsDefaultPorts = map[string]string{
+	"none": "443",
+	"smtp": "25",
+	"imap": "143",
+	"ldap": "389",
+}
+
+// tlsDefaultExpiryDays is used without an ExpiryDays option
+const tlsDefaultExpiryDays = 14
+
+// ldapStartTLSRequest is the LDAP extended request with the message id 1 and the OID of StartTLS
+var ldapStartTLSRequest = append([]byte{0x30, 0x1d, 0x02, 0x01, 0x01, 0x77, 0x18, 0x80, 0x16},
+	[]byte("1.3.6.1.4.1.1466.20037")...)
+
+// TLSCollector represents a single TLS service that should be checked
+type TLSCollector struct {
+	timeout    time.Duration
+	address    string // IP or FQDN of the server
+	port       string // Port of the TLS service
+	network    string // 'tcp', 'tcp4' or 'tcp6'
+	serverName string // Server name for SNI and the certificate check, the address if empty
+	startTLS   string // STARTTLS protocol before the handshake: none, smtp, imap or ldap
+	verify     string // what to do with an invalid certificate: fail, warn or off
+	expiryDays int    // certificates which expire within these days are invalid, 0 to disable
+}
+
+func (t *TLSCollector) New() PluginInterface {
+	return &TLSCollector{}
+}
+
+// SetConfig is used to set a config for this TestPlugin
+// The config is a map of key/value pairs
+func (t *TLSCollector) SetConfig(config map[string]string) error {
+	// Parse IPAddress
+	if _, ok := config["IPAddress"]; !ok {
+		return errors.New("missing IPAddress")
+	}
+	t.address = config["IPAddress"]
+	t.network = getNetwork("tcp", config)
+
+	// Parse STARTTLS protocol
+	t.startTLS = config["StartTLS"]
+	if t.startTLS == "" {
+		t.startTLS = "none"
+	}
+	if _, ok := tlsDefaultPorts[t.startTLS]; !ok {
+		return errors.New("invalid StartTLS")
+	}
+
+	// Parse Port, the default depends on the STARTTLS protocol
+	if v, ok := config["Port"]; !ok || v == "" {
+		t.port = tlsDefaultPorts[t.startTLS]
+	} else {
+		t.port = config["Port"]
+	}
+
+	t.serverName = config["ServerName"]
+
+	// Parse certificate checks
+	switch config["Verify"] {
+	case "":
+		t.verify = "fail"
+	case "fail", "warn", "off":
+		t.verify = config["Verify"]
+	default:
+		return errors.New("invalid Verify")
+	}
+	t.expiryDays = tlsDefaultExpiryDays
+	if v, ok := config["ExpiryDays"]; ok && v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			return errors.New("invalid ExpiryDays")
+		}
+		t.expiryDays = days
+	}
+
+	// Parse Timeout
+	if _, ok := config["Timeout"]; !ok {
+		return errors.New("missing Timeout")
+	}
+	timeout, err := time.ParseDuration(config["Timeout"])
+	if err != nil {
+		return errors.New("invalid Timeout")
+	}
+	t.timeout = timeout
+
+	return nil
+}
+
+// GetOptions returns the config options of the TLS check
+func (t *TLSCollector) GetOptions() []Option {
+	return []Option{
+		{
+			Name:        "Port",
+			Flag:        "port",
+			Type:        OptionTypeInt,
+			Description: "`port` of the TLS service (default 443 or the port of the STARTTLS protocol)",
+		},
+		{
+			Name:        "ServerName",
+			Flag:        "sni",
+			Type:        OptionTypeString,
+			Description: "`server name` which is sent and verified instead of the address",
+		},
+		{
+			Name:        "StartTLS",
+			Flag:        "starttls",
+			Type:        OptionTypeEnum,
+			Values:      []string{"none", "smtp", "imap", "ldap"},
+			Default:     "none",
+			Description: "`protocol` which is used to upgrade a plain connection with STARTTLS",
+		},
+		{
+			Name:        "Verify",
+			Flag:        "verify",
+			Type:        OptionTypeEnum,
+			Values:      []string{"fail", "warn", "off"},
+			Default:     "fail",
+			Description: "`action` for an invalid chain, a hostname mismatch or an expiring certificate",
+		},
+		{
+			Name:        "ExpiryDays",
+			Flag:        "expiry-days",
+			Type:        OptionTypeInt,
+			Default:     strconv.Itoa(tlsDefaultExpiryDays),
+			Description: "certificates which expire within these `days` are invalid, 0 to disable",
+		},
+	}
+}
+
+func (t *TLSCollector) GetName() string {
+	if t.startTLS != "none" {
+		return net.JoinHostPort(t.address, t.port) + " (" + t.startTLS + ")"
+	}
+	return net.JoinHostPort(t.address, t.port)
+}
+
+func (t *TLSCollector) SetTimeout(timeout time.Duration) {
+	t.timeout = timeout
+}
+
+// ExecuteTest connects to the service and measures the TLS handshake, the certificate is checked afterwards
+func (t *TLSCollector) ExecuteTest() (DataPointInterface, error) {
+	deadline := time.Now().Add(t.timeout)
+
+	conn, err := net.DialTimeout(t.network, net.JoinHostPort(t.address, t.port), t.timeout)
+	if err != nil {
+		return &DataPoint{
+			result:     false,
+			errorClass: getNetworkErrorClass(err),
+			message:    err.Error(),
+		}, nil
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(deadline)
+
+	if err = t.executeStartTLS(conn); err != nil {
+		errorClass := getNetworkErrorClass(err)
+		if errorClass == ErrorClassNetwork {
+			errorClass = "starttls"
+		}
+		return &DataPoint{
+			result:     false,
+			errorClass: errorClass,
+			message:    "starttls: " + err.Error(),
+		}, nil
+	}
+
+	// the certificate is checked after the handshake to measure the handshake only and to allow warnings
+	serverName := t.getServerName()
+	client := tls.Client(conn, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	start := time.Now()
+	err = client.Handshake()
+	delay := time.Since(start)
+	if err != nil {
+		errorClass := getNetworkErrorClass(err)
+		if errorClass == ErrorClassNetwork {
+			errorClass = "handshake"
+		}
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: errorClass,
+			message:    err.Error(),
+		}, nil
+	}
+
+	state := client.ConnectionState()
+	metadata := getTLSMetadata(state)
+
+	errorClass, message := t.checkCertificate(state, serverName)
+	if errorClass != "" {
+		if t.verify == "fail" {
+			return &DataPoint{
+				delay:      delay,
+				result:     false,
+				errorClass: errorClass,
+				message:    message,
+				metadata:   metadata,
+			}, nil
+		}
+		metadata["warning"] = message
+		message = "warning: " + message
+	}
+
+	return &DataPoint{
+		delay:    delay,
+		result:   true,
+		message:  message,
+		metadata: metadata,
+	}, nil
+}
+
+// getServerName returns the name which is sent with SNI and verified in the certificate
+func (t *TLSCollector) getServerName() string {
+	if t.serverName != "" {
+		return t.serverName
+	}
+	return t.address
+}
+
+// checkCertificate returns the error class and message of an invalid certificate, empty if it is valid
+func (t *TLSCollector) checkCertificate(state tls.ConnectionState, serverName string) (string, string) {
+	if t.verify == "off" {
+		return "", ""
+	}
+	if len(state.PeerCertificates) == 0 {
+		return "invalid chain", "no certificate received"
+	}
+
+	leaf := state.PeerCertificates[0]
+	now := time.Now()
+	if now.After(leaf.NotAfter) {
+		return "expired", "certificate expired at " + leaf.NotAfter.Format(time.RFC3339)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Intermediates: intermediates, CurrentTime: now}); err != nil {
+		return "invalid chain", err.Error()
+	}
+	if err := leaf.VerifyHostname(serverName); err != nil {
+		return "hostname mismatch", err.Error()
+	}
+
+	if t.expiryDays > 0 && leaf.NotAfter.Before(now.AddDate(0, 0, t.expiryDays)) {
+		return "expiring", fmt.Sprintf("certificate expires in %d days at %s",
+			int(leaf.NotAfter.Sub(now).Hours()/24), leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	return "", ""
+}
+
+// getTLSMetadata returns the details of the connection and the certificate
+func getTLSMetadata(state tls.ConnectionState) map[string]string {
+	metadata := map[string]string{
+		"protocol": tls.VersionName(state.Version),
+		"cipher":   tls.CipherSuiteName(state.CipherSuite),
+	}
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		metadata["subject"] = leaf.Subject.String()
+		metadata["issuer"] = leaf.Issuer.String()
+		metadata["expiry"] = leaf.NotAfter.Format(time.RFC3339)
+	}
+	return metadata
+}
+
+/*
+ * STARTTLS
+ */
+
+// executeStartTLS upgrades the plain connection with the STARTTLS protocol, nothing is done without one
+func (t *TLSCollector) executeStartTLS(conn net.Conn) error {
+	switch t.startTLS {
+	case "smtp":
+		return startTLSSMTP(conn)
+	case "imap":
+		return startTLSIMAP(conn)
+	case "ldap":
+		return startTLSLDAP(conn)
+	}
+	return nil
+}
+
+// startTLSSMTP waits for the greeting and sends EHLO and STARTTLS
+func startTLSSMTP(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	if _, err := readSMTPResponse(r, "220"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(conn, "EHLO parallel-check\r\n"); err != nil {
+		return err
+	}
+	if _, err := readSMTPResponse(r, "250"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(conn, "STARTTLS\r\n"); err != nil {
+		return err
+	}
+	_, err := readSMTPResponse(r, "220")
+	return err
+}
+
+// startTLSIMAP waits for the greeting and sends STARTTLS
+func startTLSIMAP(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	line, err := readLine(r)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "* OK") {
+		return fmt.Errorf("unexpected greeting %q", line)
+	}
+
+	if _, err = io.WriteString(conn, "a1 STARTTLS\r\n"); err != nil {
+		return err
+	}
+	_, err = readIMAPResponse(r, "a1")
+	return err
+}
+
+// startTLSLDAP sends the StartTLS extended request and checks the result code of the response
+func startTLSLDAP(conn net.Conn) error {
+	if _, err := conn.Write(ldapStartTLSRequest); err != nil {
+		return err
+	}
+
+	// LDAPMessage: SEQUENCE { messageID INTEGER, extendedResp [APPLICATION 24] { resultCode ENUMERATED, ... } }
+	r := bufio.NewReader(conn)
+	tag, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	length, err := readBERLength(r)
+	if err != nil {
+		return err
+	}
+	if tag != 0x30 || length > 65536 {
+		return errors.New("invalid response")
+	}
+	message := make([]byte, length)
+	if _, err = io.ReadFull(r, message); err != nil {
+		return err
+	}
+
+	m := bufio.NewReader(bytes.NewReader(message))
+	// skip the message id
+	if tag, _ = m.ReadByte(); tag != 0x02 {
+		return errors.New("invalid response")
+	}
+	if length, err = readBERLength(m); err != nil {
+		return err
+	}
+	if _, err = m.Discard(length); err != nil {
+		return errors.New("invalid response")
+	}
+	if tag, _ = m.ReadByte(); tag != 0x78 {
+		return fmt.Errorf("unexpected response 0x%02x", tag)
+	}
+	if _, err = readBERLength(m); err != nil {
+		return err
+	}
+	resultCode := make([]byte, 3)
+	if _, err = io.ReadFull(m, resultCode); err != nil || resultCode[0] != 0x0a || resultCode[1] != 0x01 {
+		return errors.New("invalid response")
+	}
+	if resultCode[2] != 0 {
+		return fmt.Errorf("result code %d", resultCode[2])
+	}
+	return nil
+}
+
+// readBERLength reads the length of a BER encoded element in short or long form
+func readBERLength(r *bufio.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b&0x80 == 0 {
+		return int(b), nil
+	}
+
+	bytes := int(b & 0x7f)
+	if bytes == 0 || bytes > 3 {
+		return 0, errors.New("invalid length")
+	}
+	length := 0
+	for i := 0; i < bytes; i++ {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	return length, nil
+}
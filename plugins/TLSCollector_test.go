@@ -0,0 +1,247 @@
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialFake connects to a fake server and limits the whole exchange to a second
+func dialFake(t *testing.T, address string) net.Conn {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", address, time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+	return conn
+}
+
+func TestStartTLSLineProtocols(t *testing.T) {
+	tests := []struct {
+		name     string
+		startTLS func(conn net.Conn) error
+		greeting string
+		replies  map[string]string
+		err      string
+	}{
+		{
+			name:     "smtp",
+			startTLS: startTLSSMTP,
+			greeting: "220-mail.example.com\r\n220 ESMTP\r\n",
+			replies: map[string]string{
+				"EHLO parallel-check": "250-mail.example.com\r\n250 STARTTLS\r\n",
+				"STARTTLS":            "220 go ahead\r\n",
+			},
+		},
+		{
+			name:     "smtp without STARTTLS",
+			startTLS: startTLSSMTP,
+			greeting: "220 ESMTP\r\n",
+			replies: map[string]string{
+				"EHLO parallel-check": "250 mail.example.com\r\n",
+				"STARTTLS":            "454 TLS not available\r\n",
+			},
+			err: `unexpected response "454 TLS not available"`,
+		},
+		{
+			name:     "smtp busy",
+			startTLS: startTLSSMTP,
+			greeting: "421 busy\r\n",
+			err:      `unexpected response "421 busy"`,
+		},
+		{
+			name:     "imap",
+			startTLS: startTLSIMAP,
+			greeting: "* OK IMAP4rev1 ready\r\n",
+			replies:  map[string]string{"a1 STARTTLS": "* CAPABILITY IMAP4rev1\r\na1 OK begin TLS\r\n"},
+		},
+		{
+			name:     "imap refused",
+			startTLS: startTLSIMAP,
+			greeting: "* OK IMAP4rev1 ready\r\n",
+			replies:  map[string]string{"a1 STARTTLS": "a1 NO not now\r\n"},
+			err:      `unexpected response "a1 NO not now"`,
+		},
+		{
+			name:     "imap preauth",
+			startTLS: startTLSIMAP,
+			greeting: "* PREAUTH logged in\r\n",
+			err:      `unexpected greeting "* PREAUTH logged in"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := dialFake(t, startBannerServer(t, test.greeting, test.replies))
+			err := test.startTLS(conn)
+			if test.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Fatalf("error = %v, want %s", err, test.err)
+			}
+		})
+	}
+}
+
+// startLDAPServer starts a fake LDAP server which checks the StartTLS request and answers with the response
+func startLDAPServer(t *testing.T, response []byte) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		request := make([]byte, len(ldapStartTLSRequest))
+		if _, err = io.ReadFull(conn, request); err != nil || !bytes.Equal(request, ldapStartTLSRequest) {
+			return
+		}
+		_, _ = conn.Write(response)
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestStartTLSLDAP(t *testing.T) {
+	tests := []struct {
+		name     string
+		response []byte
+		err      string
+	}{
+		{
+			name:     "success",
+			response: []byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00},
+		},
+		{
+			name:     "success with long form lengths",
+			response: []byte{0x30, 0x81, 0x0d, 0x02, 0x01, 0x01, 0x78, 0x81, 0x07, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00},
+		},
+		{
+			name:     "unavailable",
+			response: []byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, 0x34, 0x04, 0x00, 0x04, 0x00},
+			err:      "result code 52",
+		},
+		{
+			name:     "bind response",
+			response: []byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x61, 0x07, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00},
+			err:      "unexpected response 0x61",
+		},
+		{
+			name:     "no sequence",
+			response: []byte{0x04, 0x00},
+			err:      "invalid response",
+		},
+		{
+			name:     "result is no enumeration",
+			response: []byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x02, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00},
+			err:      "invalid response",
+		},
+		{
+			name:     "truncated",
+			response: []byte{0x30, 0x0c, 0x02, 0x01, 0x01},
+			err:      "unexpected EOF",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := dialFake(t, startLDAPServer(t, test.response))
+			err := startTLSLDAP(conn)
+			if test.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Fatalf("error = %v, want %s", err, test.err)
+			}
+		})
+	}
+}
+
+func TestReadBERLength(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		length int
+		err    bool
+	}{
+		{name: "short form", data: []byte{0x1d}, length: 29},
+		{name: "short form maximum", data: []byte{0x7f}, length: 127},
+		{name: "long form with one byte", data: []byte{0x81, 0x80}, length: 128},
+		{name: "long form with two bytes", data: []byte{0x82, 0x01, 0x00}, length: 256},
+		{name: "long form with three bytes", data: []byte{0x83, 0x01, 0x00, 0x00}, length: 65536},
+		{name: "indefinite form", data: []byte{0x80}, err: true},
+		{name: "long form with four bytes", data: []byte{0x84, 0x00, 0x00, 0x00, 0x01}, err: true},
+		{name: "truncated long form", data: []byte{0x82, 0x01}, err: true},
+		{name: "empty", data: []byte{}, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			length, err := readBERLength(bufio.NewReader(bytes.NewReader(test.data)))
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got length %d", length)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if length != test.length {
+				t.Errorf("length = %d, want %d", length, test.length)
+			}
+		})
+	}
+}
+
+func TestLDAPStartTLSRequest(t *testing.T) {
+	// the lengths of the sequence and the extended request have to match the OID
+	if int(ldapStartTLSRequest[1]) != len(ldapStartTLSRequest)-2 {
+		t.Errorf("sequence length = %d, want %d", ldapStartTLSRequest[1], len(ldapStartTLSRequest)-2)
+	}
+	if int(ldapStartTLSRequest[6]) != len(ldapStartTLSRequest)-7 {
+		t.Errorf("request length = %d, want %d", ldapStartTLSRequest[6], len(ldapStartTLSRequest)-7)
+	}
+	if !strings.HasSuffix(string(ldapStartTLSRequest), "1.3.6.1.4.1.1466.20037") {
+		t.Errorf("request does not end with the StartTLS OID")
+	}
+}
+
+func TestTLSCollectorSetConfigExpiryDays(t *testing.T) {
+	tests := []struct {
+		expiryDays string
+		want       int
+	}{
+		{expiryDays: "0", want: 0},
+		// a collector which is configured again does not keep the days of the last config
+		{want: tlsDefaultExpiryDays},
+		{expiryDays: "30", want: 30},
+	}
+
+	c := &TLSCollector{}
+	for _, test := range tests {
+		config := map[string]string{"IPAddress": "192.0.2.1", "Timeout": "1s"}
+		if test.expiryDays != "" {
+			config["ExpiryDays"] = test.expiryDays
+		}
+		if err := c.SetConfig(config); err != nil {
+			t.Fatalf("SetConfig(%v): %v", config, err)
+		}
+		if c.expiryDays != test.want {
+			t.Errorf("SetConfig(%v): expiryDays = %d, want %d", config, c.expiryDays, test.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package plugins
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	udpReplySize     = 65535 // maximum size of a reply
+	udpMetadataReply = 64    // amount of reply bytes that are shown in the metadata
+)
+
+// UDPCollector represents a single UDP service that should be checked
+type UDPCollector struct {
+	timeout time.Duration
+	address string         // IP or FQDN of the server
+	port    string         // Port of the UDP service
+	network string         // 'udp', 'udp4' or 'udp6'
+	payload []byte         // payload which is sent to the service
+	expect  *regexp.Regexp // pattern the reply has to match, any reply is fine if nil
+}
+
+func (u *UDPCollector) New() PluginInterface {
+	return &UDPCollector{}
+}
+
+// SetConfig is used to set a config for this TestPlugin
+// The config is a map of key/value pairs
+func (u *UDPCollector) SetConfig(config map[string]string) error {
+	// Parse IPAddress
+	if _, ok := config["IPAddress"]; !ok {
+		return errors.New("missing IPAddress")
+	}
+	u.address = config["IPAddress"]
+	u.network = getNetwork("udp", config)
+
+	// Parse Port, there is no useful default for UDP services
+	if v, ok := config["Port"]; !ok || v == "" {
+		return errors.New("missing Port")
+	}
+	u.port = config["Port"]
+
+	// Parse Payload
+	payload, err := parseUDPPayload(config["Payload"], config["PayloadFormat"])
+	if err != nil {
+		return err
+	}
+	u.payload = payload
+
+	// Parse expected reply
+	u.expect = nil
+	if v, ok := config["Expect"]; ok && v != "" {
+		expect, err := regexp.Compile(v)
+		if err != nil {
+			return fmt.Errorf("invalid Expect: %w", err)
+		}
+		u.expect = expect
+	}
+
+	// Parse Timeout
+	if _, ok := config["Timeout"]; !ok {
+		return errors.New("missing Timeout")
+	}
+	timeout, err := time.ParseDuration(config["Timeout"])
+	if err != nil {
+		return errors.New("invalid Timeout")
+	}
+	u.timeout = timeout
+
+	return nil
+}
+
+// parseUDPPayload returns the payload as bytes, the hex format allows spaces and colons between the bytes
+func parseUDPPayload(payload string, format string) ([]byte, error) {
+	switch format {
+	case "", "text":
+		return []byte(payload), nil
+	case "hex":
+		payload = strings.NewReplacer(" ", "", ":", "").Replace(payload)
+		b, err := hex.DecodeString(payload)
+		if err != nil {
+			return nil, errors.New("invalid Payload, expected hex")
+		}
+		return b, nil
+	}
+	return nil, errors.New("invalid PayloadFormat")
+}
+
+// GetOptions returns the config options of the UDP check
+func (u *UDPCollector) GetOptions() []Option {
+	return []Option{
+		{
+			Name:        "Port",
+			Flag:        "port",
+			Type:        OptionTypeInt,
+			Description: "`port` of the UDP service",
+		},
+		{
+			Name:        "Payload",
+			Flag:        "payload",
+			Type:        OptionTypeString,
+			Description: "`payload` which is sent to the service",
+		},
+		{
+			Name:        "PayloadFormat",
+			Flag:        "format",
+			Type:        OptionTypeEnum,
+			Values:      []string{"text", "hex"},
+			Default:     "text",
+			Description: "`format` of the payload, e.g. hex for \"ff ff ff ff 54\"",
+		},
+		{
+			Name:        "Expect",
+			Flag:        "expect",
+			Type:        OptionTypeString,
+			Description: "regular expression the reply has to match, any reply is fine if empty (`pattern`)",
+		},
+	}
+}
+
+func (u *UDPCollector) GetName() string {
+	return net.JoinHostPort(u.address, u.port)
+}
+
+func (u *UDPCollector) SetTimeout(timeout time.Duration) {
+	u.timeout = timeout
+}
+
+// ExecuteTest sends the payload and waits for the reply
+func (u *UDPCollector) ExecuteTest() (DataPointInterface, error) {
+	// the socket is connected to receive ICMP errors like port unreachable
+	conn, err := net.DialTimeout(u.network, net.JoinHostPort(u.address, u.port), u.timeout)
+	if err != nil {
+		return &DataPoint{
+			result:     false,
+			errorClass: getNetworkErrorClass(err),
+			message:    err.Error(),
+		}, nil
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(u.timeout))
+
+	reply := make([]byte, udpReplySize)
+	start := time.Now()
+	_, err = conn.Write(u.payload)
+	n := 0
+	if err == nil {
+		n, err = conn.Read(reply)
+	}
+	delay := time.Since(start)
+	if err != nil {
+		errorClass := getNetworkErrorClass(err)
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			errorClass = "port unreachable"
+		}
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: errorClass,
+			message:    err.Error(),
+		}, nil
+	}
+	reply = reply[:n]
+
+	metadata := map[string]string{
+		"bytes": strconv.Itoa(n),
+		"reply": getUDPReplyPreview(reply),
+	}
+	if u.expect != nil && !u.expect.Match(reply) {
+		return &DataPoint{
+			delay:      delay,
+			result:     false,
+			errorClass: "unexpected reply",
+			message:    "reply does not match " + u.expect.String(),
+			metadata:   metadata,
+		}, nil
+	}
+
+	// Correct result
+	return &DataPoint{
+		delay:    delay,
+		result:   true,
+		metadata: metadata,
+	}, nil
+}
+
+// getUDPReplyPreview returns the start of the reply, binary data is escaped when the metadata is shown
+func getUDPReplyPreview(reply []byte) string {
+	if len(reply) > udpMetadataReply {
+		return string(reply[:udpMetadataReply]) + "..."
+	}
+	return string(reply)
+}
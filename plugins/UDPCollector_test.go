@@ -0,0 +1,105 @@
+package plugins
+
+import (
+	"net"
+	"testing"
+)
+
+// startUDPEchoServer starts a fake service which answers each datagram with the datagram itself
+func startUDPEchoServer(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, udpReplySize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = conn.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// getClosedUDPAddress returns an address on which no service listens anymore
+func getClosedUDPAddress(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := conn.LocalAddr().String()
+	_ = conn.Close()
+	return address
+}
+
+func TestUDPCollector(t *testing.T) {
+	tests := []struct {
+		name       string
+		address    string
+		payload    string
+		format     string
+		expect     string
+		result     bool
+		errorClass string
+		reply      string
+	}{
+		{name: "reply", address: startUDPEchoServer(t), payload: "ping", result: true, reply: "ping"},
+		{
+			name:    "hex payload",
+			address: startUDPEchoServer(t),
+			payload: "70:6f 6e67",
+			format:  "hex",
+			result:  true,
+			reply:   "pong",
+		},
+		{name: "expected reply", address: startUDPEchoServer(t), payload: "ping", expect: "^pi", result: true, reply: "ping"},
+		{
+			name:       "unexpected reply",
+			address:    startUDPEchoServer(t),
+			payload:    "ping",
+			expect:     "^pong$",
+			errorClass: "unexpected reply",
+			reply:      "ping",
+		},
+		// the ICMP error of the closed port is received by the connected socket
+		{name: "closed port", address: getClosedUDPAddress(t), payload: "ping", errorClass: "port unreachable"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host, port, _ := net.SplitHostPort(test.address)
+			u := &UDPCollector{}
+			err := u.SetConfig(map[string]string{
+				"IPAddress":     host,
+				"Port":          port,
+				"Payload":       test.payload,
+				"PayloadFormat": test.format,
+				"Expect":        test.expect,
+				"Timeout":       "1s",
+			})
+			if err != nil {
+				t.Fatalf("SetConfig: %v", err)
+			}
+
+			dataPoint, err := u.ExecuteTest()
+			if err != nil {
+				t.Fatalf("ExecuteTest: %v", err)
+			}
+			if dataPoint.GetResult() != test.result || dataPoint.GetErrorClass() != test.errorClass {
+				t.Fatalf("result = %v (%q: %s), want %v (%q)", dataPoint.GetResult(), dataPoint.GetErrorClass(),
+					dataPoint.GetMessage(), test.result, test.errorClass)
+			}
+			if reply := dataPoint.GetMetadata()["reply"]; reply != test.reply {
+				t.Errorf("reply = %q, want %q", reply, test.reply)
+			}
+		})
+	}
+}
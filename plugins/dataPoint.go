@@ -2,15 +2,39 @@ package plugins
 
 import "time"
 
+// Error classes which are shared between the plugins
+const (
+	ErrorClassTimeout = "timeout" // ErrorClassTimeout is used when the test did not finish in time
+	ErrorClassPlugin  = "plugin"  // ErrorClassPlugin is used when the plugin itself failed, e.g. a crashed helper
+	ErrorClassNetwork = "network" // ErrorClassNetwork is used for all other network errors, e.g. connection refused
+)
+
 type DataPointInterface interface {
 	GetDelay() time.Duration
 	GetResult() bool
+	GetErrorClass() string
+	GetMessage() string
+	GetMetadata() map[string]string
 }
 
 // DataPoint represents a single data point
 type DataPoint struct {
-	delay  time.Duration
-	result bool
+	delay      time.Duration
+	result     bool
+	errorClass string            // short class of the error, e.g. "timeout" - empty on success
+	message    string            // human readable message for the result
+	metadata   map[string]string // additional plugin specific details of the result
+}
+
+// NewDataPoint creates a DataPoint from already known values, e.g. of a recording
+func NewDataPoint(delay time.Duration, result bool, errorClass string, message string, metadata map[string]string) DataPoint {
+	return DataPoint{
+		delay:      delay,
+		result:     result,
+		errorClass: errorClass,
+		message:    message,
+		metadata:   metadata,
+	}
 }
 
 // GetDelay returns the delay until the result was ready, return value undefined if result was false
@@ -22,3 +46,18 @@ func (p DataPoint) GetDelay() time.Duration {
 func (p DataPoint) GetResult() bool {
 	return p.result
 }
+
+// GetErrorClass returns a short class of the error, empty if the test was successful
+func (p DataPoint) GetErrorClass() string {
+	return p.errorClass
+}
+
+// GetMessage returns a human readable message for the result, can be empty
+func (p DataPoint) GetMessage() string {
+	return p.message
+}
+
+// GetMetadata returns plugin specific details of the result, can be nil
+func (p DataPoint) GetMetadata() map[string]string {
+	return p.metadata
+}
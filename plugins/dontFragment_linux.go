@@ -0,0 +1,33 @@
+package plugins
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// setDontFragment sets the DF bit on all packets of the socket. The path MTU that the kernel already learned is
+// ignored, so packets above it are still sent and answered by the router with the smaller MTU.
+func setDontFragment(conn syscall.Conn, ipv4 bool) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var optErr error
+	err = raw.Control(func(fd uintptr) {
+		if ipv4 {
+			optErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE)
+			return
+		}
+		optErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_PROBE)
+		if optErr == nil {
+			// without it IPv6 packets above the MTU of the interface are fragmented by the kernel
+			optErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_DONTFRAG, 1)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return optErr
+}
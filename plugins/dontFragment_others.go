@@ -0,0 +1,13 @@
+//go:build !linux
+
+package plugins
+
+import (
+	"errors"
+	"syscall"
+)
+
+// setDontFragment is only supported on Linux, the socket options to set the DF bit differ between the systems
+func setDontFragment(conn syscall.Conn, ipv4 bool) error {
+	return errors.New("the DF bit can only be set on Linux")
+}
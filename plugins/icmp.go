@@ -0,0 +1,274 @@
+package plugins
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+/**
+ICMP probes with special IP options like a limited TTL or the DF bit are sent with raw sockets, they receive the
+ICMP errors of routers on the path which the unprivileged ping sockets do not. Raw sockets need root or CAP_NET_RAW.
+
+Every raw socket receives all ICMP messages of the host, the probes are matched by the ID and sequence number of
+the echo request or by the ports of the UDP datagram which are also contained in the ICMP errors.
+*/
+
+const (
+	icmpProtocolIPv4 = 1  // protocol number of ICMP for icmp.ParseMessage
+	icmpProtocolIPv6 = 58 // protocol number of ICMPv6 for icmp.ParseMessage
+	icmpPayloadSize  = 56 // default size of the echo payload, the same as of ping
+	icmpHeaderSize   = 8  // size of the ICMP echo and UDP header
+	ipv4HeaderSize   = 20 // size of the IPv4 header without options
+	ipv6HeaderSize   = 40 // size of the IPv6 header without extension headers
+)
+
+// ICMP reply kinds of an icmpReply
+const (
+	icmpReplyEcho            = "echo reply"
+	icmpReplyTimeExceeded    = "time exceeded"
+	icmpReplyUnreachable     = "unreachable"
+	icmpReplyPortUnreachable = "port unreachable" // the target answers UDP probes with port unreachable
+	icmpReplyTooBig          = "too big"          // fragmentation needed or packet too big
+)
+
+// errICMPPermission is returned when the raw socket could not be opened
+var errICMPPermission = errors.New("raw ICMP sockets need root or CAP_NET_RAW")
+
+// icmpSequence is the sequence number of the last probe, the ID is the same for all probes of the process
+var icmpSequence uint32
+
+// icmpProbe is a single ICMP echo request or UDP datagram with special IP options
+type icmpProbe struct {
+	address      *net.IPAddr   // target of the probe
+	ttl          int           // TTL or hop limit of the probe, 0 for the default of the system
+	size         int           // size of the IP packet, 0 for the default payload size
+	dontFragment bool          // set DF and ignore the path MTU that the system already knows
+	udpPort      int           // send a UDP datagram to this port instead of an echo request, 0 for ICMP
+	timeout      time.Duration // time to wait for the reply
+}
+
+// icmpReply is the answer to an icmpProbe
+type icmpReply struct {
+	kind  string        // one of the icmpReply kinds
+	from  net.IP        // sender of the reply, the target or a router on the path, nil for local errors
+	mtu   int           // MTU of the next hop of a too big reply, 0 if unknown
+	delay time.Duration // round trip time
+}
+
+// isIPv4 returns true if the probe is sent with IPv4
+func (p icmpProbe) isIPv4() bool {
+	return p.address.IP.To4() != nil
+}
+
+// getPayloadSize returns the size of the echo or UDP payload
+func (p icmpProbe) getPayloadSize() int {
+	if p.size == 0 {
+		return icmpPayloadSize
+	}
+	header := ipv6HeaderSize
+	if p.isIPv4() {
+		header = ipv4HeaderSize
+	}
+	return max(p.size-header-icmpHeaderSize, 0)
+}
+
+// send sends the probe and waits for the reply, a timeout is returned as net.Error
+func (p icmpProbe) send() (icmpReply, error) {
+	network, protocol := "ip6:ipv6-icmp", icmpProtocolIPv6
+	if p.isIPv4() {
+		network, protocol = "ip4:icmp", icmpProtocolIPv4
+	}
+
+	// the ICMP errors of UDP probes are received on the raw socket as well
+	listener, err := net.ListenPacket(network, "")
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return icmpReply{}, errICMPPermission
+		}
+		return icmpReply{}, err
+	}
+	conn := listener.(*net.IPConn)
+	defer conn.Close()
+
+	start := time.Now()
+	if err = conn.SetDeadline(start.Add(p.timeout)); err != nil {
+		return icmpReply{}, err
+	}
+	isReply, err := p.write(conn)
+	if errors.Is(err, syscall.EMSGSIZE) {
+		// the probe is bigger than the MTU of the local interface
+		return icmpReply{kind: icmpReplyTooBig}, nil
+	}
+	if err != nil {
+		return icmpReply{}, err
+	}
+
+	buffer := make([]byte, 65535)
+	for {
+		n, peer, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return icmpReply{}, err
+		}
+		reply := icmpReply{delay: time.Since(start)}
+		if address, ok := peer.(*net.IPAddr); ok {
+			reply.from = address.IP
+		}
+
+		message, err := icmp.ParseMessage(protocol, buffer[:n])
+		if err != nil {
+			continue
+		}
+		switch body := message.Body.(type) {
+		case *icmp.Echo:
+			// the raw socket also receives the own echo requests of local targets
+			if message.Type == ipv4.ICMPTypeEcho || message.Type == ipv6.ICMPTypeEchoRequest || !isReply(nil, body) {
+				continue
+			}
+			reply.kind = icmpReplyEcho
+		case *icmp.TimeExceeded:
+			if !isReply(body.Data, nil) {
+				continue
+			}
+			reply.kind = icmpReplyTimeExceeded
+		case *icmp.DstUnreach:
+			if !isReply(body.Data, nil) {
+				continue
+			}
+			reply.kind = getUnreachableKind(message)
+			if reply.kind == icmpReplyTooBig {
+				// the MTU of the next hop is in the unused field of the header
+				reply.mtu = int(binary.BigEndian.Uint16(buffer[6:8]))
+			}
+		case *icmp.PacketTooBig:
+			if !isReply(body.Data, nil) {
+				continue
+			}
+			reply.kind = icmpReplyTooBig
+			reply.mtu = body.MTU
+		default:
+			continue
+		}
+		return reply, nil
+	}
+}
+
+// write sends the echo request on the raw socket or the UDP datagram on a new socket and returns the function that
+// matches the replies, either the IP packet that is contained in an ICMP error or the echo reply
+func (p icmpProbe) write(conn *net.IPConn) (func(data []byte, echo *icmp.Echo) bool, error) {
+	payload := make([]byte, p.getPayloadSize())
+
+	if p.udpPort != 0 {
+		udpConn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: p.address.IP, Port: p.udpPort, Zone: p.address.Zone})
+		if err != nil {
+			return nil, err
+		}
+		defer udpConn.Close()
+		if err = p.setOptions(udpConn); err != nil {
+			return nil, err
+		}
+		if _, err = udpConn.Write(payload); err != nil {
+			return nil, err
+		}
+		source := udpConn.LocalAddr().(*net.UDPAddr).Port
+		return func(data []byte, echo *icmp.Echo) bool {
+			return echo == nil && isUDPProbeReply(data, source, p.udpPort)
+		}, nil
+	}
+
+	request := icmp.Type(ipv6.ICMPTypeEchoRequest)
+	if p.isIPv4() {
+		request = ipv4.ICMPTypeEcho
+	}
+	id := os.Getpid() & 0xffff
+	seq := int(atomic.AddUint32(&icmpSequence, 1) & 0xffff)
+	message := icmp.Message{
+		Type: request,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: payload},
+	}
+	packet, err := message.Marshal(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.setOptions(conn); err != nil {
+		return nil, err
+	}
+	if _, err = conn.WriteTo(packet, p.address); err != nil {
+		return nil, err
+	}
+	return func(data []byte, echo *icmp.Echo) bool {
+		if echo != nil {
+			return echo.ID == id && echo.Seq == seq
+		}
+		return isICMPProbeReply(data, id, seq)
+	}, nil
+}
+
+// setOptions sets the TTL and the DF bit of the probe on the socket
+func (p icmpProbe) setOptions(conn net.Conn) error {
+	if p.dontFragment {
+		if err := setDontFragment(conn.(syscall.Conn), p.isIPv4()); err != nil {
+			return err
+		}
+	}
+	if p.ttl == 0 {
+		return nil
+	}
+	if p.isIPv4() {
+		return ipv4.NewConn(conn).SetTTL(p.ttl)
+	}
+	return ipv6.NewConn(conn).SetHopLimit(p.ttl)
+}
+
+// getUnreachableKind returns the icmpReply kind of a destination unreachable message
+func getUnreachableKind(message *icmp.Message) string {
+	if message.Type == ipv4.ICMPTypeDestinationUnreachable {
+		switch message.Code {
+		case 3:
+			return icmpReplyPortUnreachable
+		case 4:
+			return icmpReplyTooBig
+		}
+	} else if message.Code == 4 {
+		return icmpReplyPortUnreachable
+	}
+	return icmpReplyUnreachable
+}
+
+// getInnerPayload returns the ICMP or UDP header of the IP packet that is contained in an ICMP error
+func getInnerPayload(data []byte) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+
+	// the payload follows the IP header
+	header := ipv6HeaderSize
+	if data[0]>>4 == 4 {
+		header = int(data[0]&0x0f) * 4
+	}
+	if len(data) < header+icmpHeaderSize {
+		return nil
+	}
+	return data[header:]
+}
+
+// isICMPProbeReply returns true if the IP packet that is contained in an ICMP error is the probe with the id and seq
+func isICMPProbeReply(data []byte, id int, seq int) bool {
+	echo := getInnerPayload(data)
+	return echo != nil && int(binary.BigEndian.Uint16(echo[4:6])) == id && int(binary.BigEndian.Uint16(echo[6:8])) == seq
+}
+
+// isUDPProbeReply returns true if the IP packet that is contained in an ICMP error is the UDP probe between the ports
+func isUDPProbeReply(data []byte, source int, destination int) bool {
+	udp := getInnerPayload(data)
+	return udp != nil && int(binary.BigEndian.Uint16(udp[0:2])) == source &&
+		int(binary.BigEndian.Uint16(udp[2:4])) == destination
+}
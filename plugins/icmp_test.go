@@ -0,0 +1,114 @@
+package plugins
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// getProbePacket returns the start of an IPv4 packet with the given bytes of options or of an IPv6 packet which
+// contains the header of the probe
+func getProbePacket(version int, options int, probe []byte) []byte {
+	header := make([]byte, ipv6HeaderSize)
+	if version == 4 {
+		// the IPv4 header length is counted in 32 bit words
+		header = make([]byte, ipv4HeaderSize+options)
+		header[0] = byte(len(header) / 4)
+	}
+	header[0] |= byte(version << 4)
+	return append(header, probe...)
+}
+
+// getEchoHeader returns the header of an ICMP echo request
+func getEchoHeader(id uint16, seq uint16) []byte {
+	header := make([]byte, icmpHeaderSize)
+	header[0] = byte(ipv4.ICMPTypeEcho)
+	binary.BigEndian.PutUint16(header[4:], id)
+	binary.BigEndian.PutUint16(header[6:], seq)
+	return header
+}
+
+func TestIsICMPProbeReply(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet []byte
+		want   bool
+	}{
+		{name: "IPv4", packet: getProbePacket(4, 0, getEchoHeader(1234, 7)), want: true},
+		{name: "IPv4 with options", packet: getProbePacket(4, 8, getEchoHeader(1234, 7)), want: true},
+		{name: "IPv6", packet: getProbePacket(6, 0, getEchoHeader(1234, 7)), want: true},
+		{name: "other id", packet: getProbePacket(4, 0, getEchoHeader(4321, 7))},
+		{name: "other seq", packet: getProbePacket(6, 0, getEchoHeader(1234, 8))},
+		{name: "truncated", packet: getProbePacket(4, 0, getEchoHeader(1234, 7))[:ipv4HeaderSize+4]},
+		{name: "truncated IPv6", packet: getProbePacket(6, 0, getEchoHeader(1234, 7))[:ipv6HeaderSize+4]},
+		{name: "empty", packet: []byte{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isICMPProbeReply(test.packet, 1234, 7); got != test.want {
+				t.Errorf("isICMPProbeReply = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// getUDPHeader returns the header of a UDP datagram
+func getUDPHeader(source uint16, destination uint16) []byte {
+	header := make([]byte, icmpHeaderSize)
+	binary.BigEndian.PutUint16(header[0:], source)
+	binary.BigEndian.PutUint16(header[2:], destination)
+	return header
+}
+
+func TestIsUDPProbeReply(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet []byte
+		want   bool
+	}{
+		{name: "IPv4", packet: getProbePacket(4, 0, getUDPHeader(40000, 33434)), want: true},
+		{name: "IPv4 with options", packet: getProbePacket(4, 12, getUDPHeader(40000, 33434)), want: true},
+		{name: "IPv6", packet: getProbePacket(6, 0, getUDPHeader(40000, 33434)), want: true},
+		{name: "other source port", packet: getProbePacket(4, 0, getUDPHeader(40001, 33434))},
+		{name: "other destination port", packet: getProbePacket(6, 0, getUDPHeader(40000, 53))},
+		{name: "echo request", packet: getProbePacket(4, 0, getEchoHeader(40000, 33434))},
+		{name: "truncated", packet: getProbePacket(6, 0, getUDPHeader(40000, 33434))[:ipv6HeaderSize+4]},
+		{name: "empty", packet: []byte{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isUDPProbeReply(test.packet, 40000, 33434); got != test.want {
+				t.Errorf("isUDPProbeReply = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetUnreachableKind(t *testing.T) {
+	tests := []struct {
+		name    string
+		message icmp.Message
+		want    string
+	}{
+		{"IPv4 host", icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 1}, icmpReplyUnreachable},
+		{"IPv4 port", icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 3}, icmpReplyPortUnreachable},
+		{"IPv4 fragmentation needed", icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 4}, icmpReplyTooBig},
+		{"IPv4 prohibited", icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 13}, icmpReplyUnreachable},
+		{"IPv6 no route", icmp.Message{Type: ipv6.ICMPTypeDestinationUnreachable, Code: 0}, icmpReplyUnreachable},
+		{"IPv6 port", icmp.Message{Type: ipv6.ICMPTypeDestinationUnreachable, Code: 4}, icmpReplyPortUnreachable},
+		{"IPv6 prohibited", icmp.Message{Type: ipv6.ICMPTypeDestinationUnreachable, Code: 1}, icmpReplyUnreachable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := getUnreachableKind(&test.message); got != test.want {
+				t.Errorf("getUnreachableKind = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
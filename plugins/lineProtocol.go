@@ -0,0 +1,56 @@
+package plugins
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// Helpers for the line based protocols like SMTP or IMAP which are used by the TLS and banner checks
+
+// readLine reads a single line without the line break
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readSMTPResponse reads a single or multiline SMTP or FTP response, checks its code and returns the first line
+func readSMTPResponse(r *bufio.Reader, code string) (string, error) {
+	first := ""
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return first, err
+		}
+		if !strings.HasPrefix(line, code) {
+			return first, fmt.Errorf("unexpected response %q", line)
+		}
+		if first == "" {
+			first = line
+		}
+		// "250-" is followed by more lines, "250 " is the last one
+		if len(line) == len(code) || line[len(code)] != '-' {
+			return first, nil
+		}
+	}
+}
+
+// readIMAPResponse skips the untagged lines until the response with the tag and checks that it is OK
+func readIMAPResponse(r *bufio.Reader, tag string) (string, error) {
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(line, tag+" ") {
+			continue
+		}
+		if !strings.HasPrefix(line, tag+" OK") {
+			return line, fmt.Errorf("unexpected response %q", line)
+		}
+		return line, nil
+	}
+}
@@ -0,0 +1,26 @@
+package plugins
+
+import (
+	"errors"
+	"net"
+)
+
+// getNetwork returns the network for net.Dial, e.g. "tcp", "tcp4" or "tcp6" depending on the IPv4 and IPv6 config
+func getNetwork(protocol string, config map[string]string) string {
+	if v, ok := config["IPv4"]; ok && v == "true" {
+		return protocol + "4"
+	}
+	if v, ok := config["IPv6"]; ok && v == "true" {
+		return protocol + "6"
+	}
+	return protocol
+}
+
+// getNetworkErrorClass returns ErrorClassTimeout for network timeouts and ErrorClassNetwork for all other errors
+func getNetworkErrorClass(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+	return ErrorClassNetwork
+}
@@ -0,0 +1,104 @@
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OptionType defines which values are valid for an Option
+type OptionType int
+
+const (
+	OptionTypeString OptionType = iota
+	OptionTypeInt
+	OptionTypeBool
+	OptionTypeDuration
+	OptionTypeEnum
+)
+
+// Option describes a single config option of a plugin which is turned into a command line flag
+type Option struct {
+	Name        string     // Name is the key within the config map, e.g. "RecordType"
+	Flag        string     // Flag is the name of the command line flag without the plugin namespace, e.g. "type"
+	Type        OptionType // Type of the value which is validated before the config is set
+	Values      []string   // Values contains all valid values for OptionTypeEnum
+	Default     string     // Default value, empty for none
+	Description string     // Description is shown in the help, a `quoted` word is used as name of the value
+	Secret      bool       // Secret values like passwords have no command line flag and are hidden in the user interface and the API
+}
+
+// Validate returns an error if the value is not valid for this option
+func (o Option) Validate(value string) error {
+	var err error
+
+	switch o.Type {
+	case OptionTypeInt:
+		_, err = strconv.Atoi(value)
+	case OptionTypeBool:
+		_, err = strconv.ParseBool(value)
+	case OptionTypeDuration:
+		_, err = time.ParseDuration(value)
+	case OptionTypeEnum:
+		for _, v := range o.Values {
+			if v == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected %s", o.TypeName())
+	}
+
+	if err != nil {
+		return fmt.Errorf("expected %s", o.TypeName())
+	}
+	return nil
+}
+
+// TypeName returns a readable name for the type of the option
+func (o Option) TypeName() string {
+	switch o.Type {
+	case OptionTypeInt:
+		return "int"
+	case OptionTypeBool:
+		return "bool"
+	case OptionTypeDuration:
+		return "duration"
+	case OptionTypeEnum:
+		return "one of " + strings.Join(o.Values, ", ")
+	default:
+		return "string"
+	}
+}
+
+// GetUsage returns the description of the option for the help output
+func (o Option) GetUsage() string {
+	if o.Type == OptionTypeEnum {
+		return o.Description + " (" + o.TypeName() + ")"
+	}
+	return o.Description
+}
+
+// ValidateConfig checks all values of the config for which an option is described
+func ValidateConfig(options []Option, config map[string]string) error {
+	for _, o := range options {
+		value, ok := config[o.Name]
+		if !ok || value == "" {
+			continue
+		}
+		if err := o.Validate(value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", value, o.Flag, err)
+		}
+	}
+	return nil
+}
+
+// getConfigOrEnv returns the value of the config or of the environment variable if it is empty, this allows to keep
+// secrets like passwords out of the process list
+func getConfigOrEnv(config map[string]string, name string, env string) string {
+	if v, ok := config[name]; ok && v != "" {
+		return v
+	}
+	return os.Getenv(env)
+}
@@ -0,0 +1,81 @@
+package plugins
+
+import "testing"
+
+func TestValidateConfig(t *testing.T) {
+	options := []Option{
+		{Name: "Domain", Flag: "domain", Type: OptionTypeString},
+		{Name: "Port", Flag: "port", Type: OptionTypeInt},
+		{Name: "TCP", Flag: "tcp", Type: OptionTypeBool},
+		{Name: "Interval", Flag: "interval", Type: OptionTypeDuration},
+		{Name: "RecordType", Flag: "type", Type: OptionTypeEnum, Values: []string{"A", "AAAA"}},
+	}
+
+	tests := []struct {
+		name   string
+		config map[string]string
+		err    string
+	}{
+		{name: "empty", config: map[string]string{}},
+		{
+			name: "valid values",
+			config: map[string]string{
+				"Domain":     "example.org",
+				"Port":       "53",
+				"TCP":        "true",
+				"Interval":   "1m30s",
+				"RecordType": "AAAA",
+			},
+		},
+		{name: "empty values are skipped", config: map[string]string{"Port": "", "RecordType": ""}},
+		{name: "unknown keys are skipped", config: map[string]string{"IPAddress": "192.0.2.1", "Other": "x"}},
+		{name: "invalid int", config: map[string]string{"Port": "dns"}, err: `invalid value "dns" for port: expected int`},
+		{name: "invalid bool", config: map[string]string{"TCP": "yes"}, err: `invalid value "yes" for tcp: expected bool`},
+		{
+			name:   "invalid duration",
+			config: map[string]string{"Interval": "10"},
+			err:    `invalid value "10" for interval: expected duration`,
+		},
+		{
+			name:   "invalid enum",
+			config: map[string]string{"RecordType": "MX"},
+			err:    `invalid value "MX" for type: expected one of A, AAAA`,
+		},
+		{
+			name:   "enum is case sensitive",
+			config: map[string]string{"RecordType": "aaaa"},
+			err:    `invalid value "aaaa" for type: expected one of A, AAAA`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateConfig(options, test.config)
+			if test.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Fatalf("error = %v, want %s", err, test.err)
+			}
+		})
+	}
+}
+
+func TestPluginOptions(t *testing.T) {
+	// the defaults of all plugins have to be valid values of their options
+	collectors := []PluginInterface{
+		&DNSCollector{}, &PingCollector{}, &PMTUCollector{}, &TLSCollector{}, &UDPCollector{}, &NTPCollector{},
+		&BannerCollector{}, &GRPCCollector{}, &RedisCollector{}, &MemcachedCollector{},
+		NewSQLCollector(SQLDriverPostgres), NewSQLCollector(SQLDriverMySQL),
+	}
+	for _, collector := range collectors {
+		for _, option := range collector.GetOptions() {
+			if option.Default == "" {
+				continue
+			}
+			if err := option.Validate(option.Default); err != nil {
+				t.Errorf("default %q of %s is invalid: %v", option.Default, option.Flag, err)
+			}
+		}
+	}
+}
@@ -9,6 +9,19 @@ type PluginInterface interface {
 	ExecuteTest() (DataPointInterface, error) // Method is regularly called to collect a new DataPoint
 	New() PluginInterface                     // Return a new instance of this TestPlugin
 	SetTimeout(time.Duration)                 // SetTimeout is used to set/update a timeout for this TestPlugin on the run
+	GetOptions() []Option                     // Return a description of all plugin specific config options
 }
 
 type PluginConfig map[string]string
+
+// Column is an additional column of the table which shows a metadata value of the newest result
+type Column struct {
+	Header string // Header of the column, e.g. "Offset"
+	Key    string // Key of the value within the metadata of the DataPoint, e.g. "offset"
+	Width  int    // Width of the longest expected value to keep space for the column
+}
+
+// ColumnPlugin is implemented by test plugins that show details of their results in additional table columns
+type ColumnPlugin interface {
+	GetColumns() []Column // Return the additional columns of this TestPlugin
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/eiannone/keyboard"
+	"github.com/fatih/color"
+	"github.com/mattn/go-shellwords"
+)
+
+/*
+ * Prompt
+ */
+
+// Prompt is a single line input of the user interface to add targets
+type Prompt struct {
+	Active bool   // true while the user types
+	Title  string // shown in front of the input
+	Input  string // the typed text
+	Error  string // error of the last submitted input
+
+	secretPlugin string            // plugin of the copied target, the secrets are only kept for the same plugin
+	secrets      map[string]string // secret options of the copied target by their flag names, they are not shown
+}
+
+// Open shows the prompt with a prefilled input
+func (p *Prompt) Open(title string, input string) {
+	p.Active = true
+	p.Title = title
+	p.Input = input
+	p.Error = ""
+	p.secretPlugin = ""
+	p.secrets = nil
+}
+
+// OpenCopy shows the prompt prefilled with the spec of the Server, its secret options are kept without showing them
+func (p *Prompt) OpenCopy(s *Server) {
+	p.Open("Add copy", getTargetSpec(s))
+	p.secretPlugin = s.Plugin
+	p.secrets = Plugins.GetSecretValues(s.Plugin, s.Config)
+}
+
+// String returns the prompt for the user interface
+func (p *Prompt) String() string {
+	s := fmt.Sprintf("\n  %s: %s_   (Enter: Add, Escape: Cancel)\n", p.Title, p.Input)
+	if len(p.secrets) > 0 {
+		names := make([]string, 0, len(p.secrets))
+		for name := range p.secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		s += "  The hidden " + strings.Join(names, ", ") + " of the copied target are kept for the " + p.secretPlugin +
+			" plugin\n"
+	}
+	if p.Error != "" {
+		s += "  " + color.RedString("%s", p.Error) + "\n"
+	}
+	return s
+}
+
+// handlePromptInput processes a key while the prompt is active, the prompt is shown by the render, so it is changed
+// with the ServerLock held
+func handlePromptInput(event keyboard.KeyEvent) {
+	p := &globalState.Prompt
+
+	// the target is configured without the ServerLock, only the keyboard changes the input
+	var err error
+	if event.Key == keyboard.KeyEnter {
+		err = globalState.AddTargetSpec(p.Input, p.secretPlugin, p.secrets)
+	}
+
+	globalState.ServerLock.Lock()
+	defer globalState.ServerLock.Unlock()
+	switch {
+	case event.Key == keyboard.KeyEnter:
+		if err != nil {
+			p.Error = err.Error()
+			return
+		}
+		p.Active = false
+	case event.Key == keyboard.KeyEsc:
+		p.Active = false
+	case event.Key == keyboard.KeyBackspace || event.Key == keyboard.KeyBackspace2:
+		if len(p.Input) > 0 {
+			runes := []rune(p.Input)
+			p.Input = string(runes[:len(runes)-1])
+		}
+	case event.Key == keyboard.KeySpace:
+		p.Input += " "
+	case event.Rune != 0:
+		p.Input += string(event.Rune)
+	}
+}
+
+/*
+ * Target specs
+ */
+
+// AddTargetSpec adds a Server from a spec like "dns dc1=8.8.8.8 domain=example.org type=AAAA", the plugin
+// can be omitted to use the one of the command line. The secrets are added to a spec of the secretPlugin if it does
+// not set them, e.g. the password of a copied target. The ServerLock must not be held, see NewServer.
+func (gs *GlobalStateType) AddTargetSpec(spec string, secretPlugin string, secrets map[string]string) error {
+	target, err := parseTargetSpec(spec)
+	if err != nil {
+		return err
+	}
+	if target.Plugin == secretPlugin {
+		for name, value := range secrets {
+			if _, ok := target.Options[name]; !ok {
+				target.Options[name] = value
+			}
+		}
+	}
+
+	return gs.AddServer(target.Address, target.Labels, target.Plugin, target.Options)
+}
+
+// parseTargetSpec parses a spec like "dns dc1=8.8.8.8 domain=example.org", see AddTargetSpec
+func parseTargetSpec(spec string) (apiNewTarget, error) {
+	target := apiNewTarget{Plugin: PluginToUse, Options: make(map[string]string)}
+
+	words, err := shellwords.Parse(spec)
+	if err != nil {
+		return target, err
+	}
+
+	if len(words) > 0 && Plugins.IsRegistered(words[0]) {
+		target.Plugin = words[0]
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return target, errors.New("missing address")
+	}
+	target.Address, target.Labels = parseTarget(words[0])
+
+	for _, word := range words[1:] {
+		parts := strings.SplitN(word, "=", 2)
+		if len(parts) != 2 {
+			return target, fmt.Errorf("invalid option %q, expected name=value", word)
+		}
+		target.Options[parts[0]] = parts[1]
+	}
+
+	return target, nil
+}
+
+// getTargetSpec returns the spec of an existing Server, see AddTargetSpec
+func getTargetSpec(s *Server) string {
+	words := []string{s.Plugin}
+
+	target := s.Config["IPAddress"]
+	if len(s.Labels) > 0 {
+		target = strings.Join(s.Labels, ",") + "=" + target
+	}
+	words = append(words, target)
+
+	options := Plugins.GetOptionValues(s.Plugin, s.Config)
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		words = append(words, name+"="+options[name])
+	}
+
+	for i, word := range words {
+		words[i] = quoteShellWord(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// quoteShellWord quotes the word if shellwords would split or change it, within single quotes only the quote itself
+// is special and is written as '"'"'
+func quoteShellWord(word string) string {
+	if !strings.ContainsAny(word, " \t\r\n\"'\\`$();&|<>") {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mattn/go-shellwords"
+)
+
+func TestParseTargetSpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   string
+		target apiNewTarget
+		err    bool
+	}{
+		{
+			name:   "address only",
+			spec:   "8.8.8.8",
+			target: apiNewTarget{Address: "8.8.8.8", Plugin: PluginToUse, Options: map[string]string{}},
+		},
+		{
+			name:   "plugin",
+			spec:   "ping 1.1.1.1",
+			target: apiNewTarget{Address: "1.1.1.1", Plugin: "ping", Options: map[string]string{}},
+		},
+		{
+			name: "labels and options",
+			spec: "dns dc1,eu=8.8.8.8 domain=example.org type=AAAA",
+			target: apiNewTarget{
+				Address: "8.8.8.8",
+				Plugin:  "dns",
+				Labels:  []string{"dc1", "eu"},
+				Options: map[string]string{"domain": "example.org", "type": "AAAA"},
+			},
+		},
+		{
+			name: "quoted options",
+			spec: `banner 192.0.2.1 "protocol=smtp" 'port=2525' noop=tr\ue`,
+			target: apiNewTarget{
+				Address: "192.0.2.1",
+				Plugin:  "banner",
+				Options: map[string]string{"protocol": "smtp", "port": "2525", "noop": "true"},
+			},
+		},
+		{
+			name: "value with spaces and equal signs",
+			spec: `postgres db.example.org "query=SELECT 1 = 1"`,
+			target: apiNewTarget{
+				Address: "db.example.org",
+				Plugin:  "postgres",
+				Options: map[string]string{"query": "SELECT 1 = 1"},
+			},
+		},
+		{name: "empty", spec: "", err: true},
+		{name: "plugin without address", spec: "ping", err: true},
+		{name: "unknown plugin", spec: "unknown 1.1.1.1", err: true},
+		{name: "option without value", spec: "dns 8.8.8.8 domain", err: true},
+		{name: "unclosed quote", spec: "dns 8.8.8.8 'domain=example.org", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target, err := parseTargetSpec(test.spec)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", target)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(target, test.target) {
+				t.Errorf("target = %+v, want %+v", target, test.target)
+			}
+		})
+	}
+}
+
+func TestQuoteShellWord(t *testing.T) {
+	words := []string{
+		"8.8.8.8",
+		"dc1,eu=8.8.8.8",
+		"payload=hello world",
+		`payload=it's "quoted"`,
+		`payload=C:\path\with\backslashes`,
+		"query=SELECT 1; SELECT 2",
+		"payload=a|b&c<d>e",
+		"payload=$(id) `id` (x)",
+		"payload=line\nbreak\ttab",
+		"payload='",
+		"payload=",
+	}
+	for _, word := range words {
+		quoted := quoteShellWord(word)
+		parsed, err := shellwords.Parse(quoted)
+		if err != nil {
+			t.Errorf("%q quoted as %s: %v", word, quoted, err)
+			continue
+		}
+		if len(parsed) != 1 || parsed[0] != word {
+			t.Errorf("%q quoted as %s is parsed as %q", word, quoted, parsed)
+		}
+	}
+}
+
+func TestGetTargetSpec(t *testing.T) {
+	s := &Server{
+		Plugin: "udp",
+		Labels: []string{"dc1", "eu"},
+		Config: map[string]string{
+			"IPAddress": "192.0.2.1",
+			"Port":      "7",
+			"Payload":   `it's "a" payload; with $(special) \ chars`,
+		},
+	}
+	target, err := parseTargetSpec(getTargetSpec(s))
+	if err != nil {
+		t.Fatalf("parseTargetSpec(%s): %v", getTargetSpec(s), err)
+	}
+	want := apiNewTarget{
+		Address: "192.0.2.1",
+		Plugin:  "udp",
+		Labels:  []string{"dc1", "eu"},
+		Options: map[string]string{"port": "7", "payload": s.Config["Payload"]},
+	}
+	if !reflect.DeepEqual(target, want) {
+		t.Errorf("target = %+v, want %+v", target, want)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Anthrazz/parallel-check/plugins"
+)
+
+/*
+ * Recorder
+ *
+ * A recording is a plain text file that is only appended to, so an aborted run
+ * still leaves a usable file and multiple runs can be recorded into the same file.
+ * Every line starts with its type:
+ *
+ *   S <unix ms>                                    start of a run
+ *   T <server id> <plugin> <quoted name>           a server that is tested
+ *   R <ms since start>                             start of a test round
+ *   D <ms since start> <server id> <1|0> <delay µs> [<quoted error class> [<quoted message> [<metadata JSON>]]]
+ *
+ * The optional fields of a data point are written up to the last one that is set,
+ * version 1 only contained the error class.
+ */
+
+// recordingFileHeader is the first line of every run within a recording
+const recordingFileHeader = "# parallel-check recording v2"
+
+// Recorder writes all data points into a recording file
+type Recorder struct {
+	mutex     sync.Mutex
+	file      *os.File
+	writer    *bufio.Writer
+	start     time.Time
+	lastError error // last error of writing the recording, shown in the user interface
+}
+
+// NewRecorder opens the recording file and starts a new run within it
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		start:  time.Now(),
+	}
+	_, _ = fmt.Fprintf(r.writer, "%s\nS %d\n", recordingFileHeader, r.start.UnixMilli())
+
+	return r, nil
+}
+
+// AddServer writes the definition of a server, must be called before its data points are recorded
+func (r *Recorder) AddServer(s *Server) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, _ = fmt.Fprintf(r.writer, "T %d %s %s\n", s.ID, s.Plugin, strconv.Quote(s.TestPlugin.GetName()))
+}
+
+// StartRound marks the start of a new test round and writes the last round to disk
+func (r *Recorder) StartRound() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.writer.Flush(); err != nil {
+		r.lastError = fmt.Errorf("could not write recording: %w", err)
+	}
+	_, _ = fmt.Fprintf(r.writer, "R %d\n", time.Since(r.start).Milliseconds())
+}
+
+// GetError returns the last error of writing the recording
+func (r *Recorder) GetError() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.lastError
+}
+
+// AddDataPoint writes a single data point of a server
+func (r *Recorder) AddDataPoint(serverID int, dataPoint plugins.DataPointInterface) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	result := 0
+	if dataPoint.GetResult() {
+		result = 1
+	}
+	_, _ = fmt.Fprintf(r.writer, "D %d %d %d %d",
+		time.Since(r.start).Milliseconds(),
+		serverID,
+		result,
+		dataPoint.GetDelay().Microseconds(),
+	)
+	errorClass, message, metadata := dataPoint.GetErrorClass(), dataPoint.GetMessage(), dataPoint.GetMetadata()
+	if errorClass != "" || message != "" || len(metadata) > 0 {
+		_, _ = fmt.Fprintf(r.writer, " %s", strconv.Quote(errorClass))
+	}
+	if message != "" || len(metadata) > 0 {
+		_, _ = fmt.Fprintf(r.writer, " %s", strconv.Quote(message))
+	}
+	if len(metadata) > 0 {
+		// JSON escapes line breaks, the data point stays on its line
+		if data, err := json.Marshal(metadata); err == nil {
+			_, _ = fmt.Fprintf(r.writer, " %s", data)
+		}
+	}
+	_, _ = fmt.Fprintln(r.writer)
+}
+
+// Close writes all remaining data points and closes the recording
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.writer.Flush(); err != nil {
+		return err
+	}
+	return r.file.Close()
+}
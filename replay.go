@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Anthrazz/parallel-check/plugins"
+)
+
+// replaySeekStep is the time that is skipped with a single key press
+const replaySeekStep = 10 * time.Second
+
+// Limits of the replay speed factor
+const (
+	replayMinSpeed float64 = 0.125
+	replayMaxSpeed float64 = 1024
+)
+
+/*
+ * Replay
+ */
+
+// Replay feeds a recording round by round back into the Server statistics
+type Replay struct {
+	File     string        // File that is replayed
+	Speed    float64       // Speed factor of the replay, 1 is real time
+	Position time.Duration // Position is the time of the last replayed round since the start of the recording
+	Length   time.Duration // Length of the whole recording
+
+	rounds    []replayRound
+	nextRound int           // index of the next round to replay
+	seek      time.Duration // requested relative seek, applied before the next round
+	restart   bool          // requested restart from the beginning, applied before the next round
+	mutex     sync.Mutex
+}
+
+// replayRound contains all data points of a single test round
+type replayRound struct {
+	offset     time.Duration // time since the start of the recording
+	dataPoints []replayDataPoint
+}
+
+type replayDataPoint struct {
+	server    int       // index within GlobalStateType.Server
+	time      time.Time // time when the data point was recorded
+	dataPoint plugins.DataPoint
+}
+
+// LoadReplay reads a whole recording and adds all recorded servers to the global state
+func LoadReplay(path string, gs *GlobalStateType) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := &Replay{
+		File:  path,
+		Speed: 1,
+	}
+
+	var (
+		runStart  time.Duration          // offset of the current run, multiple runs are played one after the other
+		runTime   time.Time              // time when the current run was started
+		runEnd    time.Duration          // offset of the last round of the current run
+		serverIDs = make(map[int]int)    // server id within the current run -> index within gs.Server
+		servers   = make(map[string]int) // plugin + name -> index within gs.Server, to merge runs
+	)
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.SplitN(scanner.Text(), " ", 4)
+		if len(fields) < 2 || fields[0] == "#" {
+			continue
+		}
+
+		switch fields[0] {
+		case "S":
+			ms, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid start: %w", line, err)
+			}
+			runStart = runEnd
+			runTime = time.UnixMilli(ms)
+			serverIDs = make(map[int]int)
+		case "T":
+			if len(fields) != 4 {
+				return nil, fmt.Errorf("line %d: invalid server", line)
+			}
+			id, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid server id: %w", line, err)
+			}
+			name, err := strconv.Unquote(fields[3])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid server name: %w", line, err)
+			}
+
+			key := fields[2] + " " + name
+			if _, ok := servers[key]; !ok {
+				servers[key] = len(gs.Server)
+				gs.AddReplayServer(fields[2], name)
+			}
+			serverIDs[id] = servers[key]
+		case "R":
+			ms, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid round: %w", line, err)
+			}
+			runEnd = runStart + time.Duration(ms)*time.Millisecond
+			r.rounds = append(r.rounds, replayRound{offset: runEnd})
+		case "D":
+			if len(r.rounds) == 0 {
+				return nil, fmt.Errorf("line %d: data point without round", line)
+			}
+			dataPoint, server, offset, err := parseReplayDataPoint(scanner.Text(), serverIDs)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			round := &r.rounds[len(r.rounds)-1]
+			round.dataPoints = append(round.dataPoints, replayDataPoint{
+				server:    server,
+				time:      runTime.Add(offset),
+				dataPoint: dataPoint,
+			})
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(r.rounds) == 0 {
+		return nil, errors.New("recording contains no data")
+	}
+
+	r.Length = r.rounds[len(r.rounds)-1].offset
+	return r, nil
+}
+
+// parseReplayDataPoint parses a single data point line,
+// returns the data point, the index of its server and its offset within the run
+func parseReplayDataPoint(line string, serverIDs map[int]int) (plugins.DataPoint, int, time.Duration, error) {
+	fields := strings.SplitN(line, " ", 6)
+	if len(fields) < 5 {
+		return plugins.DataPoint{}, 0, 0, errors.New("invalid data point")
+	}
+
+	ms, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return plugins.DataPoint{}, 0, 0, fmt.Errorf("invalid time: %w", err)
+	}
+
+	id, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return plugins.DataPoint{}, 0, 0, fmt.Errorf("invalid server id: %w", err)
+	}
+	server, ok := serverIDs[id]
+	if !ok {
+		return plugins.DataPoint{}, 0, 0, fmt.Errorf("unknown server id %d", id)
+	}
+
+	delay, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return plugins.DataPoint{}, 0, 0, fmt.Errorf("invalid delay: %w", err)
+	}
+
+	errorClass, message, metadata := "", "", map[string]string(nil)
+	if len(fields) > 5 {
+		if errorClass, message, metadata, err = parseReplayDetails(fields[5]); err != nil {
+			return plugins.DataPoint{}, 0, 0, err
+		}
+	}
+
+	dataPoint := plugins.NewDataPoint(time.Duration(delay)*time.Microsecond, fields[3] == "1", errorClass, message,
+		metadata)
+	return dataPoint, server, time.Duration(ms) * time.Millisecond, nil
+}
+
+// parseReplayDetails parses the optional quoted error class and message and the metadata object of a data point
+func parseReplayDetails(details string) (string, string, map[string]string, error) {
+	names := []string{"error class", "message"}
+	values := make([]string, len(names))
+	for i, name := range names {
+		details = strings.TrimLeft(details, " ")
+		if details == "" || details[0] == '{' {
+			break
+		}
+		quoted, err := strconv.QuotedPrefix(details)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		values[i], _ = strconv.Unquote(quoted)
+		details = details[len(quoted):]
+	}
+
+	var metadata map[string]string
+	if details = strings.TrimSpace(details); details != "" {
+		if err := json.Unmarshal([]byte(details), &metadata); err != nil {
+			return "", "", nil, fmt.Errorf("invalid metadata: %w", err)
+		}
+	}
+	return values[0], values[1], metadata, nil
+}
+
+// Finished returns true if all rounds are replayed
+func (r *Replay) Finished() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.nextRound >= len(r.rounds)
+}
+
+// Seek requests to jump forward or backward within the recording
+func (r *Replay) Seek(d time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.seek += d
+}
+
+// Restart requests to replay the recording from the beginning
+func (r *Replay) Restart() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.restart = true
+	r.seek = 0
+}
+
+// ChangeSpeed multiplies the replay speed with factor
+func (r *Replay) ChangeSpeed(factor float64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	speed := r.Speed * factor
+	if speed >= replayMinSpeed && speed <= replayMaxSpeed {
+		r.Speed = speed
+	}
+}
+
+// applySeek jumps to the requested position, backward jumps replay everything from the start.
+// Returns true if the position was changed.
+func (r *Replay) applySeek(gs *GlobalStateType) bool {
+	r.mutex.Lock()
+	seek, restart := r.seek, r.restart
+	r.seek, r.restart = 0, false
+	target := r.Position + seek
+	r.mutex.Unlock()
+
+	if seek == 0 && !restart {
+		return false
+	}
+
+	if seek < 0 || restart {
+		gs.ServerLock.Lock()
+		gs.Reset()
+		gs.ServerLock.Unlock()
+		r.mutex.Lock()
+		r.nextRound = 0
+		r.Position = 0
+		r.mutex.Unlock()
+	}
+
+	for !restart && !r.Finished() && r.rounds[r.nextRound].offset <= target {
+		r.replayRound(gs)
+	}
+	return true
+}
+
+// replayRound feeds the next round into the servers and returns the time until the following round
+func (r *Replay) replayRound(gs *GlobalStateType) time.Duration {
+	// the render reads the servers while holding the ServerLock
+	gs.ServerLock.Lock()
+	defer gs.ServerLock.Unlock()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	round := r.rounds[r.nextRound]
+	gs.TestCounter++
+
+	for _, d := range round.dataPoints {
+		gs.Server[d.server].AddDataPoint(d.dataPoint, d.time)
+	}
+
+	r.Position = round.offset
+	r.nextRound++
+	if r.nextRound >= len(r.rounds) {
+		return 0
+	}
+
+	return time.Duration(float64(r.rounds[r.nextRound].offset-round.offset) / r.Speed)
+}
+
+// GetStatus returns a short status line of the replay for the user interface
+func (r *Replay) GetStatus() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	status := fmt.Sprintf("Replay: %s / %s | Speed: %gx", r.Position.Round(time.Second), r.Length.Round(time.Second), r.Speed)
+	if r.nextRound >= len(r.rounds) {
+		status += " | Finished"
+	}
+	return status
+}
+
+/*
+ * replayCollector
+ */
+
+// replayCollector is used as TestPlugin for replayed servers, it only knows the recorded name
+type replayCollector struct {
+	name string
+}
+
+func (c *replayCollector) SetConfig(map[string]string) error { return nil }
+func (c *replayCollector) GetName() string                   { return c.name }
+func (c *replayCollector) New() plugins.PluginInterface      { return &replayCollector{} }
+func (c *replayCollector) SetTimeout(time.Duration)          {}
+func (c *replayCollector) GetOptions() []plugins.Option      { return nil }
+
+func (c *replayCollector) ExecuteTest() (plugins.DataPointInterface, error) {
+	return nil, errors.New("replayed servers can not be tested")
+}
+
+/*
+ * replay subcommand
+ */
+
+func printReplayHelp() {
+	fmt.Println("Usage: " + os.Args[0] + " replay [<arguments>] <recording>")
+	fmt.Println()
+	fmt.Println("Replays a recording that was written with -record.")
+	fmt.Println()
+	fmt.Println("Interactive Keyboard Shortcuts:")
+	fmt.Println("  Q: Quit (Ctrl-C also while a filter is typed)")
+	fmt.Println("  P: Pause")
+	fmt.Println("  R: Restart")
+	fmt.Println("  Arrow Key Up/Down or K/J: Select Row")
+	fmt.Println("  Enter: Show/Hide Details of the selected Row")
+	fmt.Println("  S: Sort by next Column (Shift+S: Reverse Order)")
+	fmt.Println("  /: Filter Rows by Name or Label (Enter: Apply, Escape: Clear)")
+	fmt.Println("  F: Only show failing Rows")
+	fmt.Println("  H: Switch Style of the Query History")
+	fmt.Println("  C: Switch Color Scale of the Query History")
+	fmt.Println("  W: Switch Time Window of the Statistics (all, 1m, 5m, 15m)")
+	fmt.Println("  Arrow Key Left/Right: Scroll the Query History (End: Newest Tests)")
+	fmt.Println("  E: Show/Hide the Event Log of all Outages (PgUp/PgDn: Scroll)")
+	fmt.Println("  +: Double Speed")
+	fmt.Println("  -: Halve Speed")
+	fmt.Println("  [: Seek " + replaySeekStep.String() + " backward")
+	fmt.Println("  ]: Seek " + replaySeekStep.String() + " forward")
+	fmt.Println()
+	fmt.Println("Arguments:")
+	flag.CommandLine.PrintDefaults()
+}
+
+// runReplay is the replay counterpart of run()
+func runReplay(args []string) int {
+	ctx, cancelRoutines := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
+	defer cancelRoutines()
+
+	flag.CommandLine = flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := flag.Float64("speed", 1, fmt.Sprintf("replay `factor` times faster than real time (%g to %g)",
+		replayMinSpeed, replayMaxSpeed))
+	historyStyle := flag.String("history", "ascii", "`style` of the query history: ascii, sparkline or braille")
+	historyScale := flag.String("scale", "global", "color `scale` of the query history: global, target, rolling, percentile or fixed[:ms,ms,ms,ms,ms]")
+	flag.CommandLine.Usage = printReplayHelp
+	_ = flag.CommandLine.Parse(args)
+	if flag.NArg() != 1 {
+		printReplayHelp()
+		return 1
+	}
+
+	if *speed < replayMinSpeed || *speed > replayMaxSpeed {
+		fmt.Printf("Invalid -speed: %g, expected %g to %g\n", *speed, replayMinSpeed, replayMaxSpeed)
+		return 1
+	}
+
+	globalState = InitGlobalStateType()
+	var err error
+	if globalState.HistoryStyle, err = ParseHistoryStyle(*historyStyle); err != nil {
+		fmt.Printf("Invalid -history: %s\n", err)
+		return 1
+	}
+	if globalState.Scale, err = ParseHistoryScale(*historyScale); err != nil {
+		fmt.Printf("Invalid -scale: %s\n", err)
+		return 1
+	}
+	replay, err := LoadReplay(flag.Arg(0), &globalState)
+	if err != nil {
+		fmt.Printf("Could not load recording %s: %s\n", flag.Arg(0), err)
+		return 1
+	}
+	replay.ChangeSpeed(*speed)
+	globalState.Replay = replay
+	PluginToUse = replay.File
+
+	chRender := make(chan Command)
+	var wgRender sync.WaitGroup
+	wgRender.Add(1)
+	go renderRoutine(ctx, &wgRender, chRender)
+	var wgKeyboard sync.WaitGroup
+	wgKeyboard.Add(1)
+	go keyboardRoutine(ctx, &wgKeyboard, cancelRoutines, chRender)
+
+	chRender <- Command{Command: CommandTypeClearConsole}
+
+	// check regularly for key presses instead of sleeping until the next round
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	nextRoundAt := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			close(chRender)
+			wgRender.Wait()
+			wgKeyboard.Wait()
+			return 0
+		case <-ticker.C:
+			globalState.ServerLock.Lock()
+			if globalState.ResetState {
+				replay.Restart()
+				globalState.ResetState = false
+			}
+			paused := globalState.Pause
+			globalState.ServerLock.Unlock()
+			changed := replay.applySeek(&globalState)
+			if changed {
+				nextRoundAt = time.Now()
+			}
+
+			if paused || replay.Finished() {
+				// keep the time until the next round while paused
+				nextRoundAt = nextRoundAt.Add(50 * time.Millisecond)
+			} else if !time.Now().Before(nextRoundAt) {
+				globalState.ServerLock.Lock()
+				globalState.AutoScaleQueryHistory()
+				globalState.ServerLock.Unlock()
+				nextRoundAt = time.Now().Add(replay.replayRound(&globalState))
+				changed = true
+			}
+
+			if changed {
+				chRender <- Command{Command: CommandTypeRenderTable}
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Anthrazz/parallel-check/plugins"
+)
+
+func TestRecordingRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.pcrec")
+	recorder, err := NewRecorder(path)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+
+	dataPoints := []plugins.DataPoint{
+		plugins.NewDataPoint(1500*time.Microsecond, true, "", "", nil),
+		plugins.NewDataPoint(2*time.Millisecond, true, "", "warning: certificate expires in 3 days", nil),
+		plugins.NewDataPoint(time.Second, false, "timeout", "", nil),
+		plugins.NewDataPoint(3*time.Millisecond, false, "empty answer", `no records in "answer"`,
+			map[string]string{"nsid": "fra 1", "scope": "/24", "banner": "220 ready\r\nsecond line {}"}),
+		plugins.NewDataPoint(4*time.Millisecond, true, "", "", map[string]string{"mtu": "1500"}),
+	}
+	recorder.AddServer(&Server{ID: 7, Plugin: "dns", TestPlugin: &replayCollector{name: "8.8.8.8:53"}})
+	recorder.StartRound()
+	for _, dataPoint := range dataPoints {
+		recorder.AddDataPoint(7, dataPoint)
+	}
+	if err = recorder.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	gs := InitGlobalStateType()
+	replay, err := LoadReplay(path, &gs)
+	if err != nil {
+		t.Fatalf("LoadReplay: %v", err)
+	}
+	if len(replay.rounds) != 1 || len(replay.rounds[0].dataPoints) != len(dataPoints) {
+		t.Fatalf("%d rounds with %v, want 1 round with %d data points", len(replay.rounds), replay.rounds,
+			len(dataPoints))
+	}
+	for i, want := range dataPoints {
+		if got := replay.rounds[0].dataPoints[i].dataPoint; !reflect.DeepEqual(got, want) {
+			t.Errorf("data point %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestParseReplayDataPoint(t *testing.T) {
+	serverIDs := map[int]int{1: 0}
+	tests := []struct {
+		name      string
+		line      string
+		dataPoint plugins.DataPoint
+		err       bool
+	}{
+		{
+			name:      "success",
+			line:      "D 100 1 1 1500",
+			dataPoint: plugins.NewDataPoint(1500*time.Microsecond, true, "", "", nil),
+		},
+		{
+			name:      "error class of version 1",
+			line:      `D 100 1 0 1000000 "connection refused"`,
+			dataPoint: plugins.NewDataPoint(time.Second, false, "connection refused", "", nil),
+		},
+		{
+			name:      "message",
+			line:      `D 100 1 0 1000 "timeout" "read udp: i/o timeout"`,
+			dataPoint: plugins.NewDataPoint(time.Millisecond, false, "timeout", "read udp: i/o timeout", nil),
+		},
+		{
+			name: "metadata",
+			line: `D 100 1 1 1000 "" "" {"mtu":"1500"}`,
+			dataPoint: plugins.NewDataPoint(time.Millisecond, true, "", "",
+				map[string]string{"mtu": "1500"}),
+		},
+		{name: "missing delay", line: "D 100 1 1", err: true},
+		{name: "unknown server", line: "D 100 2 1 1000", err: true},
+		{name: "unquoted error class", line: "D 100 1 0 1000 timeout", err: true},
+		{name: "invalid metadata", line: `D 100 1 0 1000 "timeout" "" {"mtu":`, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dataPoint, _, offset, err := parseReplayDataPoint(test.line, serverIDs)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", dataPoint)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if offset != 100*time.Millisecond {
+				t.Errorf("offset = %s, want 100ms", offset)
+			}
+			if !reflect.DeepEqual(dataPoint, test.dataPoint) {
+				t.Errorf("data point = %+v, want %+v", dataPoint, test.dataPoint)
+			}
+		})
+	}
+}
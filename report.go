@@ -0,0 +1,360 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// reportTimeFormats are the accepted formats for -from and -to besides a duration
+var reportTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// reportTarget contains all samples of a single target within the time range
+type reportTarget struct {
+	name    string
+	samples []reportSample
+}
+
+type reportSample struct {
+	time       time.Time
+	success    bool
+	delay      time.Duration
+	errorClass string
+}
+
+// reportOutage is a period of consecutive failed samples of a target
+type reportOutage struct {
+	target  string
+	start   time.Time      // time of the first failed sample
+	end     time.Time      // time of the next successful sample, zero if the target did not recover
+	failed  int            // amount of failed samples
+	classes map[string]int // error class -> amount
+}
+
+func printReportHelp() {
+	fmt.Println("Usage: " + os.Args[0] + " report [<arguments>] <database>")
+	fmt.Println()
+	fmt.Println("Evaluates the test results that were stored with -db.")
+	fmt.Println("Times can be given as duration before now (e.g. 24h) or as date (e.g. 2006-01-02 15:04).")
+	fmt.Println()
+	fmt.Println("Arguments:")
+	flag.CommandLine.PrintDefaults()
+}
+
+// runReport is the report counterpart of run()
+func runReport(args []string) int {
+	flag.CommandLine = flag.NewFlagSet("report", flag.ExitOnError)
+	from := flag.String("from", "24h", "only evaluate results since `time`")
+	to := flag.String("to", "", "only evaluate results until `time` (default now)")
+	target := flag.String("target", "", "only evaluate targets whose name contains `text`")
+	sections := flag.String("show", "stats,errors,hourly", "comma separated `sections` that should be shown: stats, errors, hourly")
+	flag.CommandLine.Usage = printReportHelp
+	_ = flag.CommandLine.Parse(args)
+	if flag.NArg() != 1 {
+		printReportHelp()
+		return 1
+	}
+
+	start, err := parseReportTime(*from, time.Time{})
+	if err != nil {
+		fmt.Printf("Invalid -from: %s\n", err)
+		return 1
+	}
+	end, err := parseReportTime(*to, time.Now())
+	if err != nil {
+		fmt.Printf("Invalid -to: %s\n", err)
+		return 1
+	}
+
+	// do not create a new database because of a typo
+	if _, err = os.Stat(flag.Arg(0)); err != nil {
+		fmt.Printf("Could not open database: %s\n", err)
+		return 1
+	}
+	db, err := OpenDatabase(flag.Arg(0))
+	if err != nil {
+		fmt.Printf("Could not open database: %s\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	targets, err := loadReportTargets(db, start, end, *target)
+	if err != nil {
+		fmt.Printf("Could not read database: %s\n", err)
+		return 1
+	}
+
+	fmt.Printf("Report from %s until %s\n", start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05"))
+	if len(targets) == 0 {
+		fmt.Println("No results within the time range.")
+		return 0
+	}
+
+	for _, section := range strings.Split(*sections, ",") {
+		fmt.Println()
+		switch strings.TrimSpace(section) {
+		case "stats":
+			printReportStatistics(targets)
+		case "errors":
+			printReportOutages(targets)
+		case "hourly":
+			printReportHourly(targets)
+		default:
+			fmt.Printf("Unknown section %q\n", section)
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// parseReportTime parses a date or a duration before now, returns def for an empty value
+func parseReportTime(value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, format := range reportTimeFormats {
+		if t, err := time.ParseInLocation(format, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format %q", value)
+}
+
+// loadReportTargets reads all samples within the time range, the targets of multiple runs are merged by name
+func loadReportTargets(db *sql.DB, start time.Time, end time.Time, filter string) ([]*reportTarget, error) {
+	rows, err := db.Query(`
+		SELECT t.plugin, t.name, s.time, s.success, s.delay_us, s.error_class
+		FROM samples s JOIN targets t ON t.id = s.target_id
+		WHERE s.time >= ? AND s.time <= ? AND t.name LIKE ?
+		ORDER BY s.time`,
+		start.UnixMilli(), end.UnixMilli(), "%"+filter+"%",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := make([]*reportTarget, 0)
+	byName := make(map[string]*reportTarget)
+
+	for rows.Next() {
+		var (
+			plugin, name string
+			ms, delayUs  int64
+			sample       reportSample
+		)
+		if err = rows.Scan(&plugin, &name, &ms, &sample.success, &delayUs, &sample.errorClass); err != nil {
+			return nil, err
+		}
+		sample.time = time.UnixMilli(ms)
+		sample.delay = time.Duration(delayUs) * time.Microsecond
+
+		key := plugin + ": " + name
+		t, ok := byName[key]
+		if !ok {
+			t = &reportTarget{name: key}
+			byName[key] = t
+			targets = append(targets, t)
+		}
+		t.samples = append(t.samples, sample)
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].name < targets[j].name
+	})
+	return targets, rows.Err()
+}
+
+// newReportTable returns a table in the same style as the interactive one
+func newReportTable(header []string) *tablewriter.Table {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(header)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetAutoWrapText(false)
+	return table
+}
+
+// getReportRow returns the statistic columns for samples
+func getReportRow(samples []reportSample) []string {
+	errors := 0
+	delays := make([]time.Duration, 0, len(samples))
+	var sum time.Duration
+	for _, s := range samples {
+		if !s.success {
+			errors++
+			continue
+		}
+		delays = append(delays, s.delay)
+		sum += s.delay
+	}
+
+	average := time.Duration(0)
+	if len(delays) > 0 {
+		average = sum / time.Duration(len(delays))
+	}
+
+	return []string{
+		strconv.Itoa(len(samples)),
+		strconv.Itoa(errors),
+		strconv.FormatFloat(float64(errors)/float64(len(samples))*100, 'f', 2, 64) + "%",
+		formatDelay(average),
+		formatDelay(getPercentile(delays, 50)),
+		formatDelay(getPercentile(delays, 95)),
+		formatDelay(getPercentile(delays, 100)),
+	}
+}
+
+func printReportStatistics(targets []*reportTarget) {
+	fmt.Println("Statistics:")
+	table := newReportTable([]string{"Target", "Samples", "Errors", "Error %", "Average", "Median", "P95", "Worst", "Error Classes"})
+
+	for _, t := range targets {
+		classes := make(map[string]int)
+		for _, s := range t.samples {
+			if !s.success {
+				classes[s.errorClass]++
+			}
+		}
+
+		row := append([]string{t.name}, getReportRow(t.samples)...)
+		table.Append(append(row, formatErrorClasses(classes)))
+	}
+
+	table.Render()
+}
+
+func printReportOutages(targets []*reportTarget) {
+	fmt.Println("Error Timeline:")
+
+	outages := make([]reportOutage, 0)
+	for _, t := range targets {
+		var current *reportOutage
+		for _, s := range t.samples {
+			if s.success {
+				if current != nil {
+					current.end = s.time
+					outages = append(outages, *current)
+					current = nil
+				}
+				continue
+			}
+
+			if current == nil {
+				current = &reportOutage{
+					target:  t.name,
+					start:   s.time,
+					classes: make(map[string]int),
+				}
+			}
+			current.failed++
+			current.classes[s.errorClass]++
+		}
+		if current != nil {
+			outages = append(outages, *current)
+		}
+	}
+
+	if len(outages) == 0 {
+		fmt.Println("  No errors.")
+		return
+	}
+
+	sort.Slice(outages, func(i, j int) bool {
+		return outages[i].start.Before(outages[j].start)
+	})
+
+	table := newReportTable([]string{"Start", "End", "Duration", "Target", "Failed", "Error Classes"})
+	for _, o := range outages {
+		endTime, duration := "ongoing", ""
+		if !o.end.IsZero() {
+			endTime = o.end.Format("2006-01-02 15:04:05")
+			duration = o.end.Sub(o.start).Round(time.Second).String()
+		}
+		table.Append([]string{
+			o.start.Format("2006-01-02 15:04:05"),
+			endTime,
+			duration,
+			o.target,
+			strconv.Itoa(o.failed),
+			formatErrorClasses(o.classes),
+		})
+	}
+	table.Render()
+}
+
+func printReportHourly(targets []*reportTarget) {
+	fmt.Println("Hourly:")
+	table := newReportTable([]string{"Hour", "Target", "Samples", "Errors", "Error %", "Average", "Median", "P95", "Worst"})
+
+	for _, t := range targets {
+		hours := make([]time.Time, 0)
+		byHour := make(map[time.Time][]reportSample)
+		for _, s := range t.samples {
+			hour := getReportHour(s.time)
+			if _, ok := byHour[hour]; !ok {
+				hours = append(hours, hour)
+			}
+			byHour[hour] = append(byHour[hour], s)
+		}
+
+		for _, hour := range hours {
+			row := []string{hour.Format("2006-01-02 15:00"), t.name}
+			table.Append(append(row, getReportRow(byHour[hour])...))
+		}
+	}
+
+	table.Render()
+}
+
+// getReportHour returns the start of the wall clock hour of the time in its location. Truncate would count the hours
+// since the zero time in UTC, which splits the local hours of zones with an offset of half an hour.
+func getReportHour(t time.Time) time.Time {
+	return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second -
+		time.Duration(t.Nanosecond()))
+}
+
+// formatErrorClasses returns the error classes sorted by their amount, e.g. "timeout: 3, refused: 1"
+func formatErrorClasses(classes map[string]int) string {
+	names := make([]string, 0, len(classes))
+	for name := range classes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if classes[names[i]] != classes[names[j]] {
+			return classes[names[i]] > classes[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		label := name
+		if label == "" {
+			label = "error"
+		}
+		parts = append(parts, fmt.Sprintf("%s: %d", label, classes[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatDelay formats a delay the same way as the interactive table
+func formatDelay(d time.Duration) string {
+	return fmt.Sprintf("%.2f ms", float64(d/time.Microsecond)/1000)
+}
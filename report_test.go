@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetReportHour(t *testing.T) {
+	india := time.FixedZone("IST", 5*60*60+30*60)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		time time.Time
+		want time.Time
+	}{
+		{
+			name: "UTC",
+			time: time.Date(2024, 5, 1, 8, 59, 59, 999, time.UTC),
+			want: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "half hour offset",
+			time: time.Date(2024, 5, 1, 8, 10, 0, 0, india),
+			want: time.Date(2024, 5, 1, 8, 0, 0, 0, india),
+		},
+		{
+			name: "first hour before the end of daylight saving time",
+			time: time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC).In(berlin),
+			want: time.Date(2024, 10, 27, 0, 0, 0, 0, time.UTC).In(berlin),
+		},
+		{
+			name: "repeated hour after the end of daylight saving time",
+			time: time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC).In(berlin),
+			want: time.Date(2024, 10, 27, 1, 0, 0, 0, time.UTC).In(berlin),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := getReportHour(test.time)
+			if !got.Equal(test.want) {
+				t.Errorf("getReportHour(%s) = %s, want %s", test.time, got, test.want)
+			}
+			if got.Format("15:04") != test.time.Format("15")+":00" {
+				t.Errorf("hour %s does not start at the wall clock hour of %s", got, test.time)
+			}
+		})
+	}
+}
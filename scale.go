@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * HistoryScale
+ */
+
+// ScaleMode defines to which delay the entries of the query history are compared
+type ScaleMode int
+
+const (
+	ScaleModeGlobal     ScaleMode = iota // relative to the worst delay of all Server
+	ScaleModeTarget                      // relative to the worst delay of the Server itself
+	ScaleModeRolling                     // relative to the worst delay of all Server within the query history
+	ScaleModePercentile                  // by the percentiles of the delays of the Server within the query history
+	ScaleModeFixed                       // by fixed thresholds
+	scaleModeCount                       // amount of scale modes, used to cycle through them
+)
+
+var scaleModeNames = []string{"global", "target", "rolling", "percentile", "fixed"}
+
+// defaultScaleThresholds are used for ScaleModeFixed if no thresholds are given
+var defaultScaleThresholds = []time.Duration{
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+}
+
+// HistoryScale calculates the delays at which the entries of the query history reach the historyRatingLevels
+type HistoryScale struct {
+	Mode       ScaleMode
+	Thresholds []time.Duration // thresholds for ScaleModeFixed, one for each of the historyRatingLevels
+}
+
+// ParseHistoryScale parses a scale mode, the fixed mode can get thresholds in ms, e.g. "fixed:10,20,50,100,200"
+func ParseHistoryScale(value string) (HistoryScale, error) {
+	parts := strings.SplitN(value, ":", 2)
+	scale := HistoryScale{
+		Mode:       -1,
+		Thresholds: defaultScaleThresholds,
+	}
+
+	for i, name := range scaleModeNames {
+		if name == parts[0] {
+			scale.Mode = ScaleMode(i)
+		}
+	}
+	if scale.Mode == -1 {
+		return scale, errors.New("unknown scale, available: " + strings.Join(scaleModeNames, ", "))
+	}
+
+	if len(parts) == 1 {
+		return scale, nil
+	}
+	if scale.Mode != ScaleModeFixed {
+		return scale, errors.New("only the fixed scale has thresholds")
+	}
+
+	thresholds := strings.Split(parts[1], ",")
+	if len(thresholds) != len(historyRatingLevels) {
+		return scale, errors.New("the fixed scale needs " + strconv.Itoa(len(historyRatingLevels)) + " thresholds")
+	}
+	scale.Thresholds = make([]time.Duration, 0, len(thresholds))
+	for _, t := range thresholds {
+		ms, err := strconv.ParseFloat(t, 64)
+		if err != nil || ms <= 0 {
+			return scale, errors.New("invalid threshold " + t)
+		}
+		if len(scale.Thresholds) > 0 && time.Duration(ms*float64(time.Millisecond)) <= scale.Thresholds[len(scale.Thresholds)-1] {
+			return scale, errors.New("the thresholds must be ascending")
+		}
+		scale.Thresholds = append(scale.Thresholds, time.Duration(ms*float64(time.Millisecond)))
+	}
+
+	return scale, nil
+}
+
+func (h HistoryScale) String() string {
+	return scaleModeNames[h.Mode]
+}
+
+// NextMode switches to the following ScaleMode to cycle through all of them
+func (h *HistoryScale) NextMode() {
+	h.Mode = (h.Mode + 1) % scaleModeCount
+}
+
+// IsPerServer returns true if every Server has its own scale
+func (h HistoryScale) IsPerServer() bool {
+	return h.Mode == ScaleModeTarget || h.Mode == ScaleModePercentile
+}
+
+// GetSharedPoints returns the delays at which the rating reaches each of the historyRatingLevels for the modes which
+// are not per Server, nil for the per Server modes. The rolling mode scans the query history of all Server, so the
+// points are calculated once per render and passed to GetPoints.
+func (h HistoryScale) GetSharedPoints() []time.Duration {
+	switch h.Mode {
+	case ScaleModeFixed:
+		return h.Thresholds
+	case ScaleModeTarget, ScaleModePercentile:
+		return nil
+	case ScaleModeRolling:
+		worst := time.Duration(0)
+		for i := range globalState.Server {
+			for _, answer := range globalState.Server[i].GetShownAnswers() {
+				if answer.Result && answer.Delay > worst {
+					worst = answer.Delay
+				}
+			}
+		}
+		return getRelativeScalePoints(worst)
+	default:
+		return getRelativeScalePoints(globalState.WorstResponseDelay)
+	}
+}
+
+// GetPoints returns the delays at which the rating reaches each of the historyRatingLevels for the Server, the shared
+// points of GetSharedPoints are returned for the modes which are not per Server
+func (h HistoryScale) GetPoints(s *Server, shared []time.Duration) []time.Duration {
+	switch h.Mode {
+	case ScaleModeTarget:
+		return getRelativeScalePoints(s.WorstDelay)
+	case ScaleModePercentile:
+		delays := getSuccessfulDelays(s.GetShownAnswers())
+		points := make([]time.Duration, len(historyRatingLevels))
+		for i, level := range historyRatingLevels {
+			points[i] = getPercentile(delays, level*100)
+		}
+		return points
+	default:
+		return shared
+	}
+}
+
+// getRelativeScalePoints returns points for a linear scale up to the worst delay
+func getRelativeScalePoints(worst time.Duration) []time.Duration {
+	points := make([]time.Duration, len(historyRatingLevels))
+	for i, level := range historyRatingLevels {
+		points[i] = time.Duration(float64(worst) * level)
+	}
+	return points
+}
+
+// GetLegend returns the title and the points of the legend, a per Server scale is shown for the selected Server
+func (h HistoryScale) GetLegend() (string, []time.Duration) {
+	if !h.IsPerServer() {
+		return "Scale (" + h.String() + "): ", h.GetSharedPoints()
+	}
+
+	index := globalState.getServerIndex(globalState.SelectedServerID)
+	if index == -1 {
+		return "Scale (" + h.String() + "): ", getRelativeScalePoints(0)
+	}
+	return "Scale (" + h.String() + " of " + globalState.Server[index].GetName() + "): ", h.GetPoints(&globalState.Server[index], nil)
+}
+
+// getHistoryDelayRating returns an arbitrary float (lower is better) which indicates how good/bad the
+// response was. The rating reaches the historyRatingLevels at the points and is linear between them,
+// so it is between 0 and 1 for delays up to the last point.
+func getHistoryDelayRating(d time.Duration, points []time.Duration) float64 {
+	lower, lowerRating := time.Duration(0), 0.0
+	for i, point := range points {
+		if d <= point {
+			if point == lower {
+				return historyRatingLevels[i]
+			}
+			return lowerRating + (historyRatingLevels[i]-lowerRating)*float64(d-lower)/float64(point-lower)
+		}
+		lower, lowerRating = point, historyRatingLevels[i]
+	}
+
+	// above the last point
+	if lower <= 0 {
+		return 1
+	}
+	return lowerRating * float64(d) / float64(lower)
+}
+
+// getHistoryRatingDelay is the inverse of getHistoryDelayRating and returns the delay with the rating
+func getHistoryRatingDelay(rating float64, points []time.Duration) time.Duration {
+	lower, lowerRating := time.Duration(0), 0.0
+	for i, point := range points {
+		if rating <= historyRatingLevels[i] {
+			return lower + time.Duration(float64(point-lower)*(rating-lowerRating)/(historyRatingLevels[i]-lowerRating))
+		}
+		lower, lowerRating = point, historyRatingLevels[i]
+	}
+	return time.Duration(float64(lower) * rating / lowerRating)
+}
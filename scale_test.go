@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryScalePoints(t *testing.T) {
+	servers, length, worst := globalState.Server, globalState.MaximumHistoryLength, globalState.WorstResponseDelay
+	t.Cleanup(func() {
+		globalState.Server, globalState.MaximumHistoryLength, globalState.WorstResponseDelay = servers, length, worst
+	})
+
+	answers := func(delays ...time.Duration) []TestResult {
+		results := make([]TestResult, 0, len(delays))
+		for _, d := range delays {
+			results = append(results, TestResult{Delay: d, Result: d > 0})
+		}
+		return results
+	}
+	globalState.MaximumHistoryLength = 3
+	globalState.WorstResponseDelay = time.Second
+	globalState.Server = []Server{
+		// the oldest answer is not shown anymore
+		{Answers: answers(500*time.Millisecond, 10*time.Millisecond, 0, 20*time.Millisecond), WorstDelay: time.Second},
+		{Answers: answers(40*time.Millisecond, 30*time.Millisecond), WorstDelay: 50 * time.Millisecond},
+	}
+
+	tests := []struct {
+		mode  ScaleMode
+		worst []time.Duration // worst delay of the scale per Server
+	}{
+		{mode: ScaleModeGlobal, worst: []time.Duration{time.Second, time.Second}},
+		{mode: ScaleModeRolling, worst: []time.Duration{40 * time.Millisecond, 40 * time.Millisecond}},
+		{mode: ScaleModeTarget, worst: []time.Duration{time.Second, 50 * time.Millisecond}},
+		{mode: ScaleModePercentile, worst: []time.Duration{20 * time.Millisecond, 40 * time.Millisecond}},
+	}
+
+	for _, test := range tests {
+		scale := HistoryScale{Mode: test.mode}
+		shared := scale.GetSharedPoints()
+		if (shared == nil) != scale.IsPerServer() {
+			t.Errorf("%s: shared points %v, want them only for the modes which are not per Server", scale, shared)
+		}
+		for i := range globalState.Server {
+			points := scale.GetPoints(&globalState.Server[i], shared)
+			if worst := points[len(points)-1]; worst != test.worst[i] {
+				t.Errorf("%s: worst point of server %d = %s, want %s", scale, i, worst, test.worst[i])
+			}
+		}
+	}
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/Anthrazz/parallel-check/plugins"
@@ -14,21 +17,37 @@ import (
 
 // Server represents a single Server that should be tested
 type Server struct {
+	ID             int                     // unique ID of this Server within this run
+	Plugin         string                  // command line name of the used test plugin
+	Labels         []string                // labels of the user to find the Server
 	SuccessQueries int                     // amount of successful Queries
 	ErrorQueries   int                     // amount of queries with errors
 	TestPlugin     plugins.PluginInterface // TestPlugin is the interface to the test plugin which is used for this Server
+	Config         plugins.PluginConfig    // Config that was set on the TestPlugin
 	LastDelay      time.Duration           // last answer delay
 	BestDelay      time.Duration           // lowest answer delay
 	WorstDelay     time.Duration           // highest answer delay
 	DelaySum       time.Duration           // a sum of all answer delays for calculation of the averageDelay
 	AverageDelay   time.Duration           // the average answer delays
+	ErrorClasses   map[string]int          // amount of errors per error class
 	Answers        []TestResult            // slice with all TestResult's for this DNS resolver
+	Recent         []TestResult            // TestResult's of the longest StatisticWindow for the rolling statistics
+	ConfigKey      string                  // identifies the target within the config file, empty if it is not from there
+	Paused         bool                    // Set to true to skip the tests of this Server
+	Down           bool                    // true while the tests fail
+	DownSince      time.Time               // time of the first failed test of the current outage
+	FailedProbes   int                     // amount of failed tests of the current outage
+	Outages        int                     // amount of outages
+	Downtime       time.Duration           // sum of the durations of all finished outages
 }
 
 // newServer creates a new Server
 func newServer(testPluginName string) (Server, error) {
 	s := Server{
-		Answers: make([]TestResult, 0),
+		Plugin:       testPluginName,
+		ErrorClasses: make(map[string]int),
+		Answers:      make([]TestResult, 0),
+		Recent:       make([]TestResult, 0),
 	}
 
 	// Load the wanted test plugin for this server
@@ -41,6 +60,30 @@ func newServer(testPluginName string) (Server, error) {
 	return s, nil
 }
 
+// Close stops the helper processes of the test plugin, e.g. of an external plugin
+func (s *Server) Close() {
+	if closer, ok := s.TestPlugin.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+// GetName returns the name of the tested instance together with its labels
+func (s *Server) GetName() string {
+	if len(s.Labels) == 0 {
+		return s.TestPlugin.GetName()
+	}
+	return "[" + strings.Join(s.Labels, ",") + "] " + s.TestPlugin.GetName()
+}
+
+// GetErrorClasses returns a copy of the amount of errors per error class, the ServerLock must be held
+func (s *Server) GetErrorClasses() map[string]int {
+	classes := make(map[string]int, len(s.ErrorClasses))
+	for class, count := range s.ErrorClasses {
+		classes[class] = count
+	}
+	return classes
+}
+
 func (s *Server) GetQuerySum() int {
 	return s.SuccessQueries + s.ErrorQueries
 }
@@ -48,14 +91,28 @@ func (s *Server) GetErrorPercentage() float64 {
 	return float64(s.ErrorQueries) / float64(s.GetQuerySum()) * 100
 }
 
-// ExecuteQuery do query the resolver and parses the result
-func (s *Server) ExecuteQuery() {
-	dataPoint, err := s.TestPlugin.ExecuteTest()
-	if err != nil {
-		return
+// AddTestResult adds the result of a test to the sinks, the statistics and the alerts, the ServerLock must be held
+func (s *Server) AddTestResult(dataPoint plugins.DataPointInterface, timestamp time.Time) {
+	globalState.Sinks.AddDataPoint(s.ID, dataPoint)
+	s.AddDataPoint(dataPoint, timestamp)
+	if globalState.Alerts != nil {
+		globalState.Alerts.Check(s)
 	}
+}
 
-	s.AppendAnswer(dataPoint.GetDelay(), dataPoint.GetResult())
+// AddDataPoint updates the statistics of the Server with the result of a single test
+func (s *Server) AddDataPoint(dataPoint plugins.DataPointInterface, timestamp time.Time) {
+	result := TestResult{
+		Time:       timestamp,
+		Delay:      dataPoint.GetDelay(),
+		Result:     dataPoint.GetResult(),
+		ErrorClass: dataPoint.GetErrorClass(),
+		Message:    dataPoint.GetMessage(),
+		Metadata:   dataPoint.GetMetadata(),
+	}
+	s.AppendAnswer(result)
+	s.AppendRecent(result)
+	s.updateState(result)
 	if dataPoint.GetResult() {
 		s.SuccessQueries++
 		// set the last, best, worst and average answer delay for this resolver
@@ -68,17 +125,17 @@ func (s *Server) ExecuteQuery() {
 		globalState.SetWorstResponseDelay(dataPoint.GetDelay())
 	} else {
 		s.ErrorQueries++
+		s.ErrorClasses[dataPoint.GetErrorClass()]++
 	}
 
-	// delete oldest dns answer to free up not needed memory
+	// delete the oldest answers which exceed the retention
 	s.DeleteOldestTest()
-
-	return
 }
 
 func (s *Server) SetBestDelay(d time.Duration) {
-	// Default value for s.BestDelay is 0 - so set it explicit at the first query
-	if globalState.TestCounter == 1 {
+	// Default value for s.BestDelay is 0 - so set it explicit at the first successful query of this Server, it can
+	// be added or reset after the first round
+	if s.SuccessQueries == 1 {
 		s.BestDelay = d
 	} else if s.BestDelay > d {
 		s.BestDelay = d
@@ -96,28 +153,158 @@ func (s *Server) SetAverageDelay(d time.Duration) {
 	)
 }
 
-func (s *Server) AppendAnswer(delay time.Duration, result bool) {
-	s.Answers = append(s.Answers, TestResult{
-		Delay:  delay,
-		Result: result,
-	})
+func (s *Server) AppendAnswer(result TestResult) {
+	s.Answers = append(s.Answers, result)
 }
 
-// DeleteOldestTest deletes the oldest Server.Answer entry when it
-// would exceed the query history length to be displayed
-func (s *Server) DeleteOldestTest() {
-	toRemove := len(s.Answers) - globalState.MaximumHistoryLength
+// updateState tracks the outages of the Server and adds their start and end to the event log
+func (s *Server) updateState(result TestResult) {
+	if !result.Result {
+		if !s.Down {
+			s.Down = true
+			s.DownSince = result.Time
+			s.FailedProbes = 0
+			s.Outages++
+			globalState.Events.Add(Event{
+				Time:       result.Time,
+				ServerID:   s.ID,
+				Target:     s.GetName(),
+				State:      EventStateDown,
+				ErrorClass: result.ErrorClass,
+			})
+		}
+		s.FailedProbes++
+		return
+	}
+
+	if s.Down {
+		duration := result.Time.Sub(s.DownSince)
+		s.Down = false
+		s.Downtime += duration
+		globalState.Events.Add(Event{
+			Time:         result.Time,
+			ServerID:     s.ID,
+			Target:       s.GetName(),
+			State:        EventStateUp,
+			Duration:     duration,
+			FailedProbes: s.FailedProbes,
+		})
+	}
+}
+
+// GetDowntime returns the sum of the durations of all outages including the current one until now
+func (s *Server) GetDowntime(now time.Time) time.Duration {
+	if s.Down {
+		return s.Downtime + now.Sub(s.DownSince)
+	}
+	return s.Downtime
+}
+
+// AppendRecent adds the result to the rolling statistics and deletes the results
+// which are older than the longest StatisticWindow
+func (s *Server) AppendRecent(result TestResult) {
+	s.Recent = append(s.Recent, result)
+
+	since := result.Time.Add(-longestStatisticWindow)
+	toRemove := 0
+	for toRemove < len(s.Recent) && !s.Recent[toRemove].Time.After(since) {
+		toRemove++
+	}
 	if toRemove >= 1 {
-		s.Answers = s.Answers[toRemove:]
+		s.Recent = s.Recent[toRemove:]
 	}
 }
 
-// GetQueryHistory returns a pretty history of the last DNS queries
-func (s *Server) GetQueryHistory() string {
-	history := ""
+// GetStatistics returns the statistics of the tests within the window, the windows end at the newest test
+func (s *Server) GetStatistics(w StatisticWindow) Statistics {
+	if w == StatisticWindowAll {
+		return Statistics{
+			SuccessQueries: s.SuccessQueries,
+			ErrorQueries:   s.ErrorQueries,
+			LastDelay:      s.LastDelay,
+			AverageDelay:   s.AverageDelay,
+			P95Delay:       s.GetPercentileDelay(95),
+			BestDelay:      s.BestDelay,
+			WorstDelay:     s.WorstDelay,
+		}
+	}
+	if len(s.Recent) == 0 {
+		return Statistics{}
+	}
+
+	newest := s.Recent[len(s.Recent)-1].Time
+	return getWindowStatistics(s.Recent, newest.Add(-statisticWindowDurations[w]))
+}
+
+// DeleteOldestTest deletes the oldest Server.Answer entries when they
+// exceed the retention of the query history
+func (s *Server) DeleteOldestTest() {
+	s.Answers = globalState.HistoryRetention.Trim(s.Answers)
+}
 
-	for _, answer := range s.Answers {
-		history += answer.GetColoredHistoryEntry()
+// GetPercentileDelay returns the p-th percentile of the successful delays within the retained query history
+func (s *Server) GetPercentileDelay(p float64) time.Duration {
+	return getPercentile(getSuccessfulDelays(s.Answers), p)
+}
+
+// GetShownAnswers returns the part of the query history that is shown in the table
+func (s *Server) GetShownAnswers() []TestResult {
+	start, end := s.getShownRange()
+	return s.Answers[start:end]
+}
+
+// getShownRange returns the indexes of the shown part of Server.Answers, the newest tests fit into
+// the table unless the user scrolled back
+func (s *Server) getShownRange() (int, int) {
+	end := len(s.Answers) - globalState.GetHistoryOffset()
+	if end < 0 {
+		end = 0
+	}
+	start := end - globalState.MaximumHistoryLength
+	if start < 0 {
+		start = 0
+	}
+	return start, end
+}
+
+// HasErrorsInHistory returns true if a test within the shown query history has failed
+func (s *Server) HasErrorsInHistory() bool {
+	for _, answer := range s.GetShownAnswers() {
+		if !answer.Result {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMetadataValue returns the metadata value of the newest result that has the key, empty if there is none
+func (s *Server) GetMetadataValue(key string) string {
+	for i := len(s.Answers) - 1; i >= 0; i-- {
+		if value, ok := s.Answers[i].Metadata[key]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// GetQueryHistory returns a pretty history of the last DNS queries, scalePoints are the shared points of the
+// HistoryScale, see HistoryScale.GetSharedPoints
+func (s *Server) GetQueryHistory(scalePoints []time.Duration) string {
+	start, end := s.getShownRange()
+	answers := s.Answers[start:end]
+
+	switch globalState.HistoryStyle {
+	case HistoryStyleSparkline:
+		return getSparklineHistory(answers, globalState.Scale.GetPoints(s, scalePoints))
+	case HistoryStyleBraille:
+		// index of the first shown test since the start
+		return getBrailleHistory(answers, s.GetQuerySum()-len(s.Answers)+start)
+	}
+
+	points := globalState.Scale.GetPoints(s, scalePoints)
+	history := ""
+	for _, answer := range answers {
+		history += answer.GetColoredHistoryEntry(points)
 	}
 
 	return history
@@ -132,7 +319,13 @@ func (s *Server) Reset() {
 	s.WorstDelay = 0
 	s.DelaySum = 0
 	s.AverageDelay = 0
+	s.ErrorClasses = make(map[string]int)
 	s.Answers = make([]TestResult, 0)
+	s.Recent = make([]TestResult, 0)
+	s.Down = false
+	s.FailedProbes = 0
+	s.Outages = 0
+	s.Downtime = 0
 }
 
 /*
@@ -141,13 +334,18 @@ func (s *Server) Reset() {
 
 // TestResult represents a single Test result of a tested instance
 type TestResult struct {
-	Delay  time.Duration // delay between question and answer
-	Result bool          // true if the request was ok, false if not
+	Time       time.Time         // time when the result was received
+	Delay      time.Duration     // delay between question and answer
+	Result     bool              // true if the request was ok, false if not
+	ErrorClass string            // short class of the error, empty on success
+	Message    string            // message of the test plugin, e.g. the error
+	Metadata   map[string]string // plugin specific details, e.g. the certificate of the TLS check
 }
 
-func (a *TestResult) GetColoredHistoryEntry() string {
+// GetColoredHistoryEntry returns the char of the query history, points are the scale of the Server
+func (a *TestResult) GetColoredHistoryEntry(points []time.Duration) string {
 	if a.Result {
-		rating := getHistoryDelayRating(a.Delay)
+		rating := getHistoryDelayRating(a.Delay, points)
 		return getColoredHistoryEntryChar(rating)
 	} else {
 		return color.RedString("%s", "?")
@@ -158,31 +356,24 @@ func (a *TestResult) GetColoredHistoryEntry() string {
  * Helper function to get colored history
  */
 
-// getHistoryDelayRating returns an arbitrary float between 0 and 1 (lower is better) which
-// indicates how good/bad the response was in comparison to the worst response
-func getHistoryDelayRating(d time.Duration) float64 {
-	return float64(d) / float64(globalState.WorstResponseDelay)
-}
-
 // show a scale for the usage of the color in the query history
 func getHistoryColorScale() string {
-	scale := "Scale: "
-
-	worstDelay := globalState.WorstResponseDelay
-
-	delays := []float64{
-		float64(worstDelay) * 0.6,
-		float64(worstDelay) * 0.8,
-		float64(worstDelay) * 0.9,
-		float64(worstDelay) * 0.95,
-		float64(worstDelay) * 1,
+	switch globalState.HistoryStyle {
+	case HistoryStyleSparkline:
+		return getSparklineColorScale()
+	case HistoryStyleBraille:
+		return getBrailleColorScale()
 	}
 
-	for _, delay := range delays {
-		r := getHistoryDelayRating(time.Duration(delay))
-		d := time.Duration(delay)
+	scale, points := globalState.Scale.GetLegend()
 
-		scale += fmt.Sprintf("%s < %dms ", getColoredHistoryEntryChar(r), d.Milliseconds())
+	for i, level := range historyRatingLevels {
+		// fixed thresholds do not end at the worst delay
+		if globalState.Scale.Mode == ScaleModeFixed && i == len(historyRatingLevels)-1 {
+			scale += fmt.Sprintf("%s >= %dms ", getColoredHistoryEntryChar(level), points[i-1].Milliseconds())
+			continue
+		}
+		scale += fmt.Sprintf("%s < %dms ", getColoredHistoryEntryChar(level), points[i].Milliseconds())
 	}
 
 	return scale
@@ -190,16 +381,49 @@ func getHistoryColorScale() string {
 
 // return a useful char so the user know how bad the delay is
 func getColoredHistoryEntryChar(rating float64) string {
-	switch {
-	case rating <= 0.6:
-		return color.GreenString("%s", ".")
-	case rating <= 0.8:
-		return color.CyanString("%s", "-")
-	case rating <= 0.9:
-		return color.BlueString("%s", "+")
-	case rating <= 0.95:
-		return color.YellowString("%s", "*")
-	default:
-		return color.MagentaString("%s", "#")
+	level := getHistoryRatingLevel(rating)
+	return historyRatingColors[level]("%s", historyASCIIChars[level])
+}
+
+// getHistoryRatingLevel returns the index within historyRatingLevels for the rating
+func getHistoryRatingLevel(rating float64) int {
+	for i, level := range historyRatingLevels {
+		if rating <= level {
+			return i
+		}
+	}
+	return len(historyRatingLevels) - 1
+}
+
+// getSuccessfulDelays returns the delays of all successful tests
+func getSuccessfulDelays(answers []TestResult) []time.Duration {
+	delays := make([]time.Duration, 0, len(answers))
+	for _, answer := range answers {
+		if answer.Result {
+			delays = append(delays, answer.Delay)
+		}
+	}
+	return delays
+}
+
+// getPercentile returns the p-th percentile (0-100) of delays with the nearest-rank method, 0 without delays
+func getPercentile(delays []time.Duration, p float64) time.Duration {
+	if len(delays) == 0 {
+		return 0
+	}
+
+	sorted := make([]time.Duration, len(delays))
+	copy(sorted, delays)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	rank := int(p/100*float64(len(sorted))+0.999999) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
 	}
+	return sorted[rank]
 }
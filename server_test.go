@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Anthrazz/parallel-check/plugins"
+)
+
+func TestServerDelays(t *testing.T) {
+	s := Server{TestPlugin: &plugins.DNSCollector{}, ErrorClasses: make(map[string]int)}
+	start := time.Now()
+	results := []plugins.DataPoint{
+		plugins.NewDataPoint(time.Second, false, "timeout", "", nil),
+		plugins.NewDataPoint(30*time.Millisecond, true, "", "", nil),
+		plugins.NewDataPoint(10*time.Millisecond, true, "", "", nil),
+		plugins.NewDataPoint(2*time.Second, false, "timeout", "", nil),
+	}
+	for i, dataPoint := range results {
+		s.AddDataPoint(dataPoint, start.Add(time.Duration(i)*time.Second))
+	}
+
+	// the all-time average divides the successful delays by all tests up to the last success
+	if want := 40 * time.Millisecond / 3; s.AverageDelay != want {
+		t.Errorf("average delay = %s, want %s", s.AverageDelay, want)
+	}
+	// best and worst delay only include the successful tests, even if the first test failed
+	if s.BestDelay != 10*time.Millisecond {
+		t.Errorf("best delay = %s, want 10ms", s.BestDelay)
+	}
+	if s.WorstDelay != 30*time.Millisecond {
+		t.Errorf("worst delay = %s, want 30ms", s.WorstDelay)
+	}
+	if s.SuccessQueries != 2 || s.ErrorQueries != 2 || s.ErrorClasses["timeout"] != 2 {
+		t.Errorf("%d successes and %d errors (%v), want 2 and 2", s.SuccessQueries, s.ErrorQueries, s.ErrorClasses)
+	}
+}
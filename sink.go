@@ -0,0 +1,54 @@
+package main
+
+import "github.com/Anthrazz/parallel-check/plugins"
+
+// ResultSink receives all test results, e.g. to write them into a file or a database
+type ResultSink interface {
+	AddServer(s *Server)                                             // AddServer is called for every Server before its first result
+	StartRound()                                                     // StartRound is called at the start of every test round
+	AddDataPoint(serverID int, dataPoint plugins.DataPointInterface) // AddDataPoint is called concurrently for every test result
+	GetError() error                                                 // GetError returns the last error of writing the results
+	Close() error                                                    // Close is called at exit
+}
+
+// ResultSinks passes all results to multiple ResultSink
+type ResultSinks []ResultSink
+
+func (r ResultSinks) AddServer(s *Server) {
+	for _, sink := range r {
+		sink.AddServer(s)
+	}
+}
+
+func (r ResultSinks) StartRound() {
+	for _, sink := range r {
+		sink.StartRound()
+	}
+}
+
+func (r ResultSinks) AddDataPoint(serverID int, dataPoint plugins.DataPointInterface) {
+	for _, sink := range r {
+		sink.AddDataPoint(serverID, dataPoint)
+	}
+}
+
+// GetError returns the first error of the sinks, nil if all results were written
+func (r ResultSinks) GetError() error {
+	for _, sink := range r {
+		if err := sink.GetError(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all sinks and returns the first error
+func (r ResultSinks) Close() error {
+	var firstErr error
+	for _, sink := range r {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Anthrazz/parallel-check/plugins"
+	_ "modernc.org/sqlite" // pure Go SQLite driver
+)
+
+// storageSchema creates all tables of the database if they do not exist yet
+const storageSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id         INTEGER PRIMARY KEY,
+	started_at INTEGER NOT NULL, -- unix ms
+	plugin     TEXT NOT NULL,
+	arguments  TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS targets (
+	id        INTEGER PRIMARY KEY,
+	run_id    INTEGER NOT NULL REFERENCES runs(id),
+	plugin    TEXT NOT NULL,
+	name      TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS samples (
+	target_id   INTEGER NOT NULL REFERENCES targets(id),
+	time        INTEGER NOT NULL, -- unix ms
+	success     INTEGER NOT NULL,
+	delay_us    INTEGER NOT NULL,
+	error_class TEXT NOT NULL DEFAULT '',
+	message     TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS samples_time ON samples (time, target_id);
+`
+
+// Storage writes all test results into a SQLite database
+type Storage struct {
+	mutex     sync.Mutex
+	db        *sql.DB
+	runID     int64
+	targets   map[int]int64   // Server.ID -> targets.id
+	pending   []storageSample // samples of the current round, written at the start of the next round
+	lastError error           // last error of writing into the database, shown in the user interface
+}
+
+// storageSample is a single test result that is not yet written
+type storageSample struct {
+	targetID  int64
+	time      time.Time
+	dataPoint plugins.DataPointInterface
+}
+
+// OpenDatabase opens the SQLite database and creates the schema if needed
+func OpenDatabase(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only allows a single writer
+	db.SetMaxOpenConns(1)
+
+	if _, err = db.Exec(storageSchema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// storageSecretFlags are the command line flags whose values are not stored with the arguments of a run
+var storageSecretFlags = []string{"api-token"}
+
+// getRedactedArguments returns a copy of the command line arguments with hidden values of the secret flags
+func getRedactedArguments(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	for i := 0; i < len(redacted); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(redacted[i], "-"), "=")
+		if !strings.HasPrefix(redacted[i], "-") || !slices.Contains(storageSecretFlags, name) {
+			continue
+		}
+		if hasValue {
+			redacted[i] = redacted[i][:strings.Index(redacted[i], "=")+1] + "***"
+		} else if i+1 < len(redacted) {
+			i++
+			redacted[i] = "***"
+		}
+	}
+	return redacted
+}
+
+// NewStorage opens the database and creates a new run within it
+func NewStorage(path string, plugin string) (*Storage, error) {
+	db, err := OpenDatabase(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := db.Exec(
+		"INSERT INTO runs (started_at, plugin, arguments) VALUES (?, ?, ?)",
+		time.Now().UnixMilli(), plugin, strings.Join(getRedactedArguments(os.Args[1:]), " "),
+	)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	runID, err := result.LastInsertId()
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &Storage{
+		db:      db,
+		runID:   runID,
+		targets: make(map[int]int64),
+	}, nil
+}
+
+// AddServer stores the Server as target of the current run, its results are not stored if this fails
+func (st *Storage) AddServer(s *Server) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	result, err := st.db.Exec(
+		"INSERT INTO targets (run_id, plugin, name) VALUES (?, ?, ?)",
+		st.runID, s.Plugin, s.TestPlugin.GetName(),
+	)
+	if err != nil {
+		st.lastError = fmt.Errorf("could not store target %s: %w", s.TestPlugin.GetName(), err)
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		st.lastError = fmt.Errorf("could not store target %s: %w", s.TestPlugin.GetName(), err)
+		return
+	}
+	st.targets[s.ID] = id
+}
+
+// StartRound writes all samples of the last round within a single transaction, they are kept for the next round if
+// this fails
+func (st *Storage) StartRound() {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	if err := st.flush(); err != nil {
+		st.lastError = fmt.Errorf("could not store results: %w", err)
+	}
+}
+
+// GetError returns the last error of writing into the database
+func (st *Storage) GetError() error {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	return st.lastError
+}
+
+// AddDataPoint remembers the test result until the round is finished
+func (st *Storage) AddDataPoint(serverID int, dataPoint plugins.DataPointInterface) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	targetID, ok := st.targets[serverID]
+	if !ok {
+		return
+	}
+	st.pending = append(st.pending, storageSample{
+		targetID:  targetID,
+		time:      time.Now(),
+		dataPoint: dataPoint,
+	})
+}
+
+// Close writes the remaining samples and closes the database
+func (st *Storage) Close() error {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	if err := st.flush(); err != nil {
+		_ = st.db.Close()
+		return err
+	}
+	return st.db.Close()
+}
+
+// flush writes all pending samples, the mutex must be held
+func (st *Storage) flush() error {
+	if len(st.pending) == 0 {
+		return nil
+	}
+
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(
+		"INSERT INTO samples (target_id, time, success, delay_us, error_class, message) VALUES (?, ?, ?, ?, ?, ?)",
+	)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, sample := range st.pending {
+		_, err = stmt.Exec(
+			sample.targetID,
+			sample.time.UnixMilli(),
+			sample.dataPoint.GetResult(),
+			sample.dataPoint.GetDelay().Microseconds(),
+			sample.dataPoint.GetErrorClass(),
+			sample.dataPoint.GetMessage(),
+		)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	st.pending = st.pending[:0]
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Anthrazz/parallel-check/plugins"
+)
+
+func TestStorageKeepsSamplesOnError(t *testing.T) {
+	st, err := NewStorage(filepath.Join(t.TempDir(), "results.sqlite"), "dns")
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer st.Close()
+
+	st.AddServer(&Server{ID: 1, Plugin: "dns", TestPlugin: &plugins.DNSCollector{}})
+	if err = st.GetError(); err != nil {
+		t.Fatalf("AddServer: %v", err)
+	}
+	dataPoint := plugins.NewDataPoint(0, false, "timeout", "no answer", nil)
+	st.AddDataPoint(1, dataPoint)
+
+	// the samples of a failed write are kept for the next round
+	if _, err = st.db.Exec("DROP TABLE samples"); err != nil {
+		t.Fatal(err)
+	}
+	st.StartRound()
+	if st.GetError() == nil {
+		t.Fatal("expected an error of the failed write")
+	}
+	if len(st.pending) != 1 {
+		t.Fatalf("%d pending samples after the failed write, want 1", len(st.pending))
+	}
+
+	if _, err = st.db.Exec(storageSchema); err != nil {
+		t.Fatal(err)
+	}
+	st.AddDataPoint(1, dataPoint)
+	st.StartRound()
+	if len(st.pending) != 0 {
+		t.Fatalf("%d pending samples after the write, want 0", len(st.pending))
+	}
+	var count int
+	if err = st.db.QueryRow("SELECT COUNT(*) FROM samples").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("%d stored samples, want 2", count)
+	}
+}
+
+func TestStorageAddServerError(t *testing.T) {
+	st, err := NewStorage(filepath.Join(t.TempDir(), "results.sqlite"), "dns")
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer st.Close()
+
+	if _, err = st.db.Exec("DROP TABLE targets"); err != nil {
+		t.Fatal(err)
+	}
+	st.AddServer(&Server{ID: 1, Plugin: "dns", TestPlugin: &plugins.DNSCollector{}})
+	if st.GetError() == nil {
+		t.Error("expected an error of the failed insert")
+	}
+}
+
+func TestGetRedactedArguments(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{
+			args: []string{"-p", "dns", "-api", "127.0.0.1:8080", "-api-token", "secret", "8.8.8.8"},
+			want: []string{"-p", "dns", "-api", "127.0.0.1:8080", "-api-token", "***", "8.8.8.8"},
+		},
+		{
+			args: []string{"--api-token=secret", "8.8.8.8"},
+			want: []string{"--api-token=***", "8.8.8.8"},
+		},
+		{
+			args: []string{"-w", "2s", "-api-token"},
+			want: []string{"-w", "2s", "-api-token"},
+		},
+		{
+			// only flags are redacted, not targets with the same name
+			args: []string{"api-token", "secret"},
+			want: []string{"api-token", "secret"},
+		},
+	}
+
+	for _, test := range tests {
+		if got := getRedactedArguments(test.args); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("getRedactedArguments(%q) = %q, want %q", test.args, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Anthrazz/parallel-check/plugins"
+)
+
+/*
+ * TableView
+ */
+
+// SortColumn is the column by which the table is sorted
+type SortColumn int
+
+const (
+	SortColumnNone SortColumn = iota // insertion order
+	SortColumnName
+	SortColumnSuccess
+	SortColumnErrors
+	SortColumnErrorPercentage
+	SortColumnLast
+	SortColumnAverage
+	SortColumnP95
+	SortColumnBest
+	SortColumnWorst
+	sortColumnCount // amount of sort columns, used to cycle through them
+)
+
+// sortColumnHeaders maps the SortColumn to the index of the table header
+var sortColumnHeaders = map[SortColumn]int{
+	SortColumnName:            0,
+	SortColumnSuccess:         1,
+	SortColumnErrors:          2,
+	SortColumnErrorPercentage: 3,
+	SortColumnLast:            4,
+	SortColumnAverage:         5,
+	SortColumnP95:             6,
+	SortColumnBest:            7,
+	SortColumnWorst:           8,
+}
+
+// TableView contains how the rows of the table are sorted, filtered and scrolled
+type TableView struct {
+	SortColumn     SortColumn      // column by which the rows are sorted
+	SortDescending bool            // Set to true to sort from high to low
+	Filter         string          // only show rows whose name or labels contain this text
+	FilterInput    bool            // true while the user types the filter
+	OnlyFailing    bool            // only show rows with errors within the query history
+	Offset         int             // index of the first shown row if not all rows fit into the terminal
+	Window         StatisticWindow // time range of the statistics within the table
+	HistoryEnd     int             // TestCounter of the newest shown test if the query history is scrolled back, 0 to follow
+}
+
+// NextSortColumn sorts the table by the next column
+func (v *TableView) NextSortColumn() {
+	v.SortColumn = (v.SortColumn + 1) % sortColumnCount
+}
+
+// GetHeader returns the table header with a marker at the sorted column
+func (v *TableView) GetHeader(header []string) []string {
+	index, ok := sortColumnHeaders[v.SortColumn]
+	if !ok {
+		return header
+	}
+
+	h := make([]string, len(header))
+	copy(h, header)
+	if v.SortDescending {
+		h[index] += " ↓"
+	} else {
+		h[index] += " ↑"
+	}
+	return h
+}
+
+// GetStatus returns a short description of the active sorting and filtering for the user interface
+func (v *TableView) GetStatus() string {
+	status := make([]string, 0)
+	if v.FilterInput {
+		status = append(status, "Filter: "+v.Filter+"_")
+	} else if v.Filter != "" {
+		status = append(status, "Filter: "+v.Filter)
+	}
+	if v.OnlyFailing {
+		status = append(status, "Only Failing")
+	}
+	if v.Window != StatisticWindowAll {
+		status = append(status, "Statistics: last "+v.Window.String())
+	}
+	return strings.Join(status, " | ")
+}
+
+// GetRows returns the indexes of all Server that are shown in the sorted order
+func (v *TableView) GetRows(servers []Server) []int {
+	rows := make([]int, 0, len(servers))
+	for i := range servers {
+		if v.matches(&servers[i]) {
+			rows = append(rows, i)
+		}
+	}
+
+	if v.SortColumn == SortColumnNone {
+		return rows
+	}
+
+	// collect the sorted values once per row, the statistics sort the whole query history of a Server
+	names := make(map[int]string, len(rows))
+	values := make(map[int]float64, len(rows))
+	for _, row := range rows {
+		if v.SortColumn == SortColumnName {
+			names[row] = servers[row].GetName()
+		} else {
+			values[row] = v.getSortValue(&servers[row])
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if v.SortDescending {
+			a, b = b, a
+		}
+
+		if v.SortColumn == SortColumnName {
+			return names[a] < names[b]
+		}
+		return values[a] < values[b]
+	})
+	return rows
+}
+
+// Scroll adjusts the offset so that the selected row is visible within height rows
+func (v *TableView) Scroll(selected int, height int) {
+	if selected < v.Offset {
+		v.Offset = selected
+	}
+	if selected >= v.Offset+height {
+		v.Offset = selected - height + 1
+	}
+	if v.Offset < 0 {
+		v.Offset = 0
+	}
+}
+
+// matches returns true if the Server should be shown
+func (v *TableView) matches(s *Server) bool {
+	if v.OnlyFailing && !s.HasErrorsInHistory() {
+		return false
+	}
+	if v.Filter == "" {
+		return true
+	}
+
+	// the name contains the labels
+	return strings.Contains(strings.ToLower(s.GetName()), strings.ToLower(v.Filter))
+}
+
+// getMetadataColumns returns the additional columns of the test plugins of all Server, every metadata key only once
+// with the widest width, e.g. the hops of IPv6 targets need more space than the ones of IPv4 targets
+func getMetadataColumns(servers []Server) []plugins.Column {
+	columns := make([]plugins.Column, 0)
+	known := make(map[string]int)
+	for i := range servers {
+		p, ok := servers[i].TestPlugin.(plugins.ColumnPlugin)
+		if !ok {
+			continue
+		}
+		for _, column := range p.GetColumns() {
+			index, ok := known[column.Key]
+			if !ok {
+				known[column.Key] = len(columns)
+				columns = append(columns, column)
+				continue
+			}
+			columns[index].Width = max(columns[index].Width, column.Width)
+		}
+	}
+	return columns
+}
+
+// getMetadataColumnsWidth returns the space which is needed for the additional columns within the table
+func getMetadataColumnsWidth(columns []plugins.Column) int {
+	width := 0
+	for _, column := range columns {
+		// the column separator needs 3 chars
+		if len(column.Header) > column.Width {
+			width += len(column.Header) + 3
+		} else {
+			width += column.Width + 3
+		}
+	}
+	return width
+}
+
+// getSortValue returns the numeric value of the sorted column
+func (v *TableView) getSortValue(s *Server) float64 {
+	st := s.GetStatistics(v.Window)
+	switch v.SortColumn {
+	case SortColumnSuccess:
+		return float64(st.SuccessQueries)
+	case SortColumnErrors:
+		return float64(st.ErrorQueries)
+	case SortColumnErrorPercentage:
+		return st.GetErrorPercentage()
+	case SortColumnLast:
+		return float64(st.LastDelay)
+	case SortColumnAverage:
+		return float64(st.AverageDelay)
+	case SortColumnP95:
+		return float64(st.P95Delay)
+	case SortColumnBest:
+		return float64(st.BestDelay)
+	case SortColumnWorst:
+		return float64(st.WorstDelay)
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Anthrazz/parallel-check/plugins"
+)
+
+func TestTableViewGetRows(t *testing.T) {
+	servers := []Server{
+		{TestPlugin: &replayCollector{name: "b"}, SuccessQueries: 5, ErrorQueries: 1, AverageDelay: 30 * time.Millisecond},
+		{
+			TestPlugin: &replayCollector{name: "c"}, Labels: []string{"dc1"},
+			SuccessQueries: 6, AverageDelay: 10 * time.Millisecond,
+		},
+		{TestPlugin: &replayCollector{name: "a"}, SuccessQueries: 2, ErrorQueries: 4, AverageDelay: 20 * time.Millisecond},
+	}
+
+	tests := []struct {
+		name string
+		view TableView
+		want []int
+	}{
+		{name: "insertion order", view: TableView{}, want: []int{0, 1, 2}},
+		// the labels are part of the name
+		{name: "name", view: TableView{SortColumn: SortColumnName}, want: []int{1, 2, 0}},
+		{name: "average", view: TableView{SortColumn: SortColumnAverage}, want: []int{1, 2, 0}},
+		{
+			name: "errors descending",
+			view: TableView{SortColumn: SortColumnErrors, SortDescending: true},
+			want: []int{2, 0, 1},
+		},
+		{name: "filter by label", view: TableView{SortColumn: SortColumnAverage, Filter: "DC1"}, want: []int{1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.view.GetRows(servers); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("GetRows() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetMetadataColumns(t *testing.T) {
+	newPing := func(address string) *plugins.PingCollector {
+		p := &plugins.PingCollector{}
+		if err := p.SetConfig(map[string]string{"IPAddress": address, "Timeout": "1s", "TTL": "1"}); err != nil {
+			t.Fatalf("SetConfig(%s): %v", address, err)
+		}
+		return p
+	}
+	servers := []Server{
+		{TestPlugin: &replayCollector{name: "no columns"}},
+		{TestPlugin: newPing("127.0.0.1")},
+		{TestPlugin: newPing("::1")},
+	}
+
+	columns := getMetadataColumns(servers)
+	if len(columns) != 1 || columns[0].Key != "hop" || columns[0].Width != 39 {
+		t.Errorf("getMetadataColumns() = %+v, want one hop column with the width of an IPv6 address", columns)
+	}
+}
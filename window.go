@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * StatisticWindow
+ */
+
+// StatisticWindow is the time range of the statistics that are shown in the table
+type StatisticWindow int
+
+const (
+	StatisticWindowAll   StatisticWindow = iota // all tests since the start or the last reset
+	StatisticWindow1m                           // tests of the last minute
+	StatisticWindow5m                           // tests of the last 5 minutes
+	StatisticWindow15m                          // tests of the last 15 minutes
+	statisticWindowCount                        // amount of windows, used to cycle through them
+)
+
+var statisticWindowNames = []string{"all", "1m", "5m", "15m"}
+var statisticWindowDurations = []time.Duration{0, time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// longestStatisticWindow is how long the tests are kept for the rolling statistics
+var longestStatisticWindow = statisticWindowDurations[len(statisticWindowDurations)-1]
+
+func (w StatisticWindow) String() string {
+	return statisticWindowNames[w]
+}
+
+// Next returns the following StatisticWindow to cycle through all of them
+func (w StatisticWindow) Next() StatisticWindow {
+	return (w + 1) % statisticWindowCount
+}
+
+/*
+ * Statistics
+ */
+
+// Statistics contains the statistics of a Server within a StatisticWindow
+type Statistics struct {
+	SuccessQueries int           // amount of successful Queries
+	ErrorQueries   int           // amount of queries with errors
+	LastDelay      time.Duration // last answer delay
+	AverageDelay   time.Duration // the average answer delay
+	P95Delay       time.Duration // the 95th percentile of the answer delays
+	BestDelay      time.Duration // lowest answer delay
+	WorstDelay     time.Duration // highest answer delay
+}
+
+func (st Statistics) GetQuerySum() int {
+	return st.SuccessQueries + st.ErrorQueries
+}
+
+// GetErrorPercentage returns the percentage of failed tests, 0 without tests
+func (st Statistics) GetErrorPercentage() float64 {
+	if st.GetQuerySum() == 0 {
+		return 0
+	}
+	return float64(st.ErrorQueries) / float64(st.GetQuerySum()) * 100
+}
+
+// getWindowStatistics calculates the Statistics of all results which are newer than since
+func getWindowStatistics(results []TestResult, since time.Time) Statistics {
+	st := Statistics{}
+	delays := make([]time.Duration, 0, len(results))
+	sum := time.Duration(0)
+
+	for _, result := range results {
+		if !result.Time.After(since) {
+			continue
+		}
+		if !result.Result {
+			st.ErrorQueries++
+			continue
+		}
+
+		st.SuccessQueries++
+		st.LastDelay = result.Delay
+		if st.BestDelay == 0 || result.Delay < st.BestDelay {
+			st.BestDelay = result.Delay
+		}
+		if result.Delay > st.WorstDelay {
+			st.WorstDelay = result.Delay
+		}
+		sum += result.Delay
+		delays = append(delays, result.Delay)
+	}
+
+	if st.SuccessQueries > 0 {
+		st.AverageDelay = sum / time.Duration(st.SuccessQueries)
+	}
+	st.P95Delay = getPercentile(delays, 95)
+
+	return st
+}
+
+// getStatisticsTable returns the error percentage and latency of all windows next to each other for the detail pane
+func getStatisticsTable(s *Server) string {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("  %-8s %8s %8s %12s %12s\n", "Window", "Tests", "Error %", "Average", "P95"))
+	for w := StatisticWindowAll; w < statisticWindowCount; w++ {
+		st := s.GetStatistics(w)
+		b.WriteString(fmt.Sprintf("  %-8s %8d %8s %12s %12s\n", w, st.GetQuerySum(),
+			strconv.FormatFloat(st.GetErrorPercentage(), 'f', 2, 64)+"%", formatDelay(st.AverageDelay), formatDelay(st.P95Delay)))
+	}
+	return b.String()
+}